@@ -0,0 +1,219 @@
+// Package cassette records the requests and responses going through an http.RoundTripper to a file, scrubbing secrets
+// and personal data, and replays them later without touching the network.
+package cassette
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Request is a recorded request
+type Request struct {
+	Method string      `json:"method"`
+	Url    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// Response is a recorded response, with its body decompressed
+type Response struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// Interaction is a single request and the response it received
+type Interaction struct {
+	Request    Request   `json:"request"`
+	Response   Response  `json:"response"`
+	RecordedAt time.Time `json:"recordedAt"`
+}
+
+// Cassette is the contents of a cassette file
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Load reads a cassette file
+func Load(path string) (*Cassette, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cassette Cassette
+	if err := json.Unmarshal(raw, &cassette); err != nil {
+		return nil, fmt.Errorf("invalid cassette %s: %w", path, err)
+	}
+	return &cassette, nil
+}
+
+// Save writes the cassette to a file
+func (c *Cassette) Save(path string) error {
+	marshalled, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(marshalled, '\n'), 0644)
+}
+
+// Recorder passes requests on to the next RoundTripper, recording a scrubbed copy of each interaction
+type Recorder struct {
+	next      http.RoundTripper
+	scrubbers []Scrubber
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// NewRecorder creates a Recorder sending requests through next (nil for http.DefaultTransport),
+// applying the scrubbers to each interaction before it is recorded.
+func NewRecorder(next http.RoundTripper, scrubbers ...Scrubber) *Recorder {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Recorder{next: next, scrubbers: scrubbers}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The body is read for the recording, so the request gets a fresh copy of it
+	var requestBody []byte
+	if req.Body != nil {
+		var err error
+		requestBody, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(requestBody))
+	}
+
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Likewise for the response, which is handed back untouched
+	responseBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(responseBody))
+
+	// Only the recording is decompressed, so that it can be scrubbed and read
+	header := resp.Header.Clone()
+	if header.Get("Content-Encoding") == "gzip" {
+		if decoded, err := gunzip(responseBody); err == nil {
+			responseBody = decoded
+			header.Del("Content-Encoding")
+			header.Del("Content-Length")
+		}
+	}
+
+	interaction := Interaction{
+		Request:    Request{Method: req.Method, Url: req.URL.String(), Header: req.Header.Clone(), Body: string(requestBody)},
+		Response:   Response{Status: resp.StatusCode, Header: header, Body: string(responseBody)},
+		RecordedAt: time.Now(),
+	}
+	for _, scrub := range r.scrubbers {
+		scrub(&interaction)
+	}
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	r.mu.Unlock()
+
+	return resp, nil
+}
+
+// Save writes every interaction recorded so far to a cassette file
+func (r *Recorder) Save(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cassette.Save(path)
+}
+
+// Replayer answers requests from a cassette, never touching the network.
+// Each request is answered by the first unused interaction with the same method and URL; once every such
+// interaction has been used, the last one keeps being replayed.
+type Replayer struct {
+	cassette *Cassette
+
+	mu   sync.Mutex
+	used []bool
+}
+
+// NewReplayer creates a Replayer for the cassette
+func NewReplayer(cassette *Cassette) *Replayer {
+	return &Replayer{cassette: cassette, used: make([]bool, len(cassette.Interactions))}
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	interaction, found := r.match(req.Method, req.URL.String())
+	if !found {
+		return nil, fmt.Errorf("cassette has no interaction for %s %s", req.Method, req.URL)
+	}
+
+	header := interaction.Response.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set("Content-Length", strconv.Itoa(len(interaction.Response.Body)))
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", interaction.Response.Status, http.StatusText(interaction.Response.Status)),
+		StatusCode:    interaction.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader([]byte(interaction.Response.Body))),
+		ContentLength: int64(len(interaction.Response.Body)),
+		Request:       req,
+	}, nil
+}
+
+// match finds the interaction to replay for a request, marking it as used
+func (r *Replayer) match(method string, url string) (Interaction, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	last := -1
+	for i, interaction := range r.cassette.Interactions {
+		if interaction.Request.Method != method || interaction.Request.Url != url {
+			continue
+		}
+		if !r.used[i] {
+			r.used[i] = true
+			return interaction, true
+		}
+		last = i
+	}
+
+	if last == -1 {
+		return Interaction{}, false
+	}
+	return r.cassette.Interactions[last], true
+}
+
+func gunzip(body []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
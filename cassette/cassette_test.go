@@ -0,0 +1,176 @@
+package cassette_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/time/rate"
+
+	"unsubscribe/cassette"
+	"unsubscribe/directory"
+	"unsubscribe/fakemarketo"
+	"unsubscribe/fakeutsa"
+	"unsubscribe/ratelimit"
+	"unsubscribe/scla"
+	"unsubscribe/store"
+	"unsubscribe/web"
+)
+
+// session logs in, scrapes a letter, fetches every person on it and unsubscribes the first, returning what was scraped
+func session(t *testing.T, webClient *web.Client, utsaUrl string, sclaUrl string) ([]directory.Entry, []*directory.FullEntry) {
+	t.Helper()
+	ctx := context.Background()
+
+	utsaClient := directory.NewClient(webClient, store.NewMemoryStore())
+	utsaClient.BaseUrl = utsaUrl
+	if err := utsaClient.Login(ctx, "student", "hunter2"); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if loggedIn, err := utsaClient.CheckLoggedIn(ctx); err != nil || !loggedIn {
+		t.Fatalf("CheckLoggedIn = %t, %v; want true, nil", loggedIn, err)
+	}
+
+	entries, err := utsaClient.GetDirectory(ctx, 'A')
+	if err != nil {
+		t.Fatalf("GetDirectory: %v", err)
+	}
+	fulls := make([]*directory.FullEntry, len(entries))
+	for i, entry := range entries {
+		fulls[i], err = utsaClient.GetFullEntry(ctx, entry.Id)
+		if err != nil {
+			t.Fatalf("GetFullEntry(%s): %v", entry.Id, err)
+		}
+	}
+
+	sclaClient := scla.NewClient(webClient, store.NewMemoryStore())
+	sclaClient.Form.BaseUrl = sclaUrl
+	if _, err := sclaClient.Unsubscribe(ctx, fulls[0].Email); err != nil {
+		t.Fatalf("Unsubscribe: %v", err)
+	}
+
+	return entries, fulls
+}
+
+// newWebClient creates a web client sending requests through the given transport, without rate limiting those to the fake server
+func newWebClient(transport http.RoundTripper, serverUrl string) *web.Client {
+	client := web.NewClientWithTransport(transport)
+	client.Limiters.SetLimit(serverUrl, ratelimit.Limit{Rate: rate.Inf, Burst: 1})
+	return client
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	people := fakeutsa.GeneratePeople(1, 26*3)
+	utsaServer := httptest.NewServer(fakeutsa.New("student", "hunter2", people))
+	sclaServer := httptest.NewServer(fakemarketo.New(fakemarketo.Succeed))
+	defer utsaServer.Close()
+	defer sclaServer.Close()
+
+	// Record a session
+	recorder := cassette.NewRecorder(utsaServer.Client().Transport, cassette.DefaultScrubbers()...)
+	recordedEntries, recordedFulls := session(t, newWebClient(recorder, utsaServer.URL), utsaServer.URL, sclaServer.URL)
+
+	path := filepath.Join(t.TempDir(), "session.json")
+	if err := recorder.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing personal or secret may be left in the cassette
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets := []string{"hunter2"}
+	for i, entry := range recordedEntries {
+		full := recordedFulls[i]
+		secrets = append(secrets, entry.Id, url.QueryEscape(entry.Id), entry.Name, entry.Phone,
+			full.Email, full.Name, full.MailingAddress, full.Building, full.Major, full.Classification)
+	}
+	for _, secret := range secrets {
+		if secret != "" && strings.Contains(string(raw), secret) {
+			t.Errorf("cassette contains %q", secret)
+		}
+	}
+
+	// The fake's people must include both students and staff, or the fields above went untested
+	hasStudent := slices.ContainsFunc(recordedFulls, func(full *directory.FullEntry) bool { return full.Major != "" && full.Classification != "" })
+	hasStaff := slices.ContainsFunc(recordedFulls, func(full *directory.FullEntry) bool { return full.MailingAddress != "" && full.Building != "" })
+	if !hasStudent || !hasStaff {
+		t.Fatalf("scraped entries %+v do not include both a student and a member of staff", recordedFulls)
+	}
+	if !strings.Contains(string(raw), ".ADAuthCookie="+cassette.Scrubbed) {
+		t.Error("cassette does not contain the scrubbed auth cookie")
+	}
+
+	// Replay it with both servers gone
+	utsaServer.Close()
+	sclaServer.Close()
+
+	loaded, err := cassette.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayedEntries, replayedFulls := session(t, newWebClient(cassette.NewReplayer(loaded), utsaServer.URL), utsaServer.URL, sclaServer.URL)
+
+	if len(replayedEntries) != len(recordedEntries) {
+		t.Fatalf("replayed %d entries, recorded %d", len(replayedEntries), len(recordedEntries))
+	}
+	for i := range replayedEntries {
+		replayed, recorded := replayedEntries[i], recordedEntries[i]
+		if !strings.HasPrefix(replayed.Id, "ID-") || replayed.Name == recorded.Name || replayed.JobTitle != recorded.JobTitle {
+			t.Errorf("replayed entry %+v, want %+v under a pseudonymous ID and name", replayed, recorded)
+		}
+
+		replayedFull, recordedFull := replayedFulls[i], recordedFulls[i]
+		if !strings.HasSuffix(replayedFull.Email, "@example.com") || replayedFull.Title != recordedFull.Title {
+			t.Errorf("replayed full entry %+v, want %+v with a pseudonymous email", replayedFull, recordedFull)
+		}
+		if recordedFull.Major != "" && replayedFull.Major != cassette.Scrubbed {
+			t.Errorf("replayed major %q, want it scrubbed", replayedFull.Major)
+		}
+	}
+}
+
+func TestReplayUnknownRequest(t *testing.T) {
+	replayer := cassette.NewReplayer(&cassette.Cassette{})
+
+	_, err := replayer.RoundTrip(httptest.NewRequest("GET", "https://www.utsa.edu/directory/AdvancedSearch", nil))
+	if err == nil || !strings.Contains(err.Error(), "cassette has no interaction") {
+		t.Fatalf("RoundTrip from an empty cassette = %v, want a missing interaction error", err)
+	}
+}
+
+func TestDefaultScrubbersIgnoreAttributeOrder(t *testing.T) {
+	interaction := cassette.Interaction{
+		Request: cassette.Request{Url: "https://www.utsa.edu/directory/Person_Detail?abc=c2VjcmV0%2BaWQ%3D"},
+		Response: cassette.Response{Body: `<a href="/directory/Person_Detail?abc=c2VjcmV0%2baWQ%3d" title="Details" class="person fullName">Jordan Secret</a>
+<span id="name" class="nameBold large"> <strong>Jordan Q. Secret</strong></span>
+<tr>
+    <th><strong>Mailing Address:</strong></th>
+    <td>
+        123 Hidden Lane
+    </td>
+</tr>`},
+	}
+	for _, scrub := range cassette.DefaultScrubbers() {
+		scrub(&interaction)
+	}
+
+	for _, secret := range []string{"Jordan", "Secret", "c2VjcmV0", "Hidden Lane"} {
+		if strings.Contains(interaction.Request.Url+interaction.Response.Body, secret) {
+			t.Errorf("scrubbed interaction still contains %q:\n%s\n%s", secret, interaction.Request.Url, interaction.Response.Body)
+		}
+	}
+
+	// The ID is escaped differently in the link and the request, but must be given the same pseudonym
+	_, requestId, _ := strings.Cut(interaction.Request.Url, "abc=")
+	if !strings.Contains(interaction.Response.Body, "abc="+requestId+`"`) {
+		t.Errorf("link and request were given different pseudonyms: %s, %s", requestId, interaction.Response.Body)
+	}
+}
@@ -0,0 +1,161 @@
+package cassette
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Scrubbed replaces any value that must not be recorded
+const Scrubbed = "SCRUBBED"
+
+// Scrubber edits an interaction before it is recorded, removing anything that should not be kept
+type Scrubber func(interaction *Interaction)
+
+// DefaultScrubbers returns the scrubbers for UTSA directory and Marketo sessions: cookie values, credentials, tokens,
+// and the emails, names, IDs, phone numbers, addresses, buildings, majors and classifications of the people in the directory.
+// Emails, names and IDs are replaced with pseudonyms that are consistent within, but not across, recordings, so that
+// a replayed session still requests the detail page of each person it finds.
+func DefaultScrubbers() []Scrubber {
+	salt := make([]byte, 16)
+	rand.Read(salt)
+
+	return []Scrubber{
+		ScrubHeaders("Authorization"),
+		ScrubCookies(),
+		ScrubFormFields("myUTSAID", "passphrase", "__RequestVerificationToken", "mkt_tok", "checksum"),
+		ScrubMatches(regexp.MustCompile(`(name="__RequestVerificationToken"[^>]*value=")[^"]*(")`), func(groups []string) string {
+			return groups[1] + Scrubbed + groups[2]
+		}),
+		ScrubMatches(regexp.MustCompile(`mkt_tok(=|%3D)[\w-]+`), func(groups []string) string {
+			return "mkt_tok" + groups[1] + Scrubbed
+		}),
+		ScrubMatches(regexp.MustCompile(`[\w.+-]+(@|%40)([\w-]+(?:\.[\w-]+)+)`), func(groups []string) string {
+			return pseudonym(salt, "person", strings.ToLower(groups[0])) + groups[1] + "example.com"
+		}),
+		ScrubMatches(regexp.MustCompile(`(<a\b[^>]*\bclass="[^"]*\bfullName\b[^"]*"[^>]*>)([^<]*)(</a>)`), func(groups []string) string {
+			return groups[1] + pseudonym(salt, "Name", strings.TrimSpace(groups[2])) + groups[3]
+		}),
+		ScrubMatches(regexp.MustCompile(`(<span\b[^>]*\bclass="[^"]*\bnameBold\b[^"]*"[^>]*>\s*<strong>)([^<]*)(</strong>)`), func(groups []string) string {
+			return groups[1] + pseudonym(salt, "Name", strings.TrimSpace(groups[2])) + groups[3]
+		}),
+		// IDs are escaped differently in links and request URLs, so the pseudonym is of the unescaped ID
+		ScrubMatches(regexp.MustCompile(`(Person_Detail\?abc=)([^"&\s]+)`), func(groups []string) string {
+			id, err := url.QueryUnescape(groups[2])
+			if err != nil {
+				id = groups[2]
+			}
+			return groups[1] + pseudonym(salt, "ID", id)
+		}),
+		ScrubMatches(regexp.MustCompile(`(?is)(<th>\s*<strong>\s*(?:Mailing\s+Address|Building|Major|Classification)\s*:?\s*</strong>\s*</th>\s*<td>)(.*?)(</td>)`), func(groups []string) string {
+			return groups[1] + Scrubbed + groups[3]
+		}),
+		ScrubMatches(regexp.MustCompile(`\(\d{3}\) \d{3}-\d{4}`), func(groups []string) string {
+			return "(000) 000-0000"
+		}),
+	}
+}
+
+// pseudonym derives a stable replacement for a value from a salted hash of it
+func pseudonym(salt []byte, prefix string, value string) string {
+	hash := sha256.Sum256(append(append([]byte{}, salt...), value...))
+	return fmt.Sprintf("%s-%x", prefix, hash[:4])
+}
+
+// ScrubHeaders replaces the values of the given request and response headers
+func ScrubHeaders(names ...string) Scrubber {
+	return func(interaction *Interaction) {
+		for _, name := range names {
+			for _, header := range []http.Header{interaction.Request.Header, interaction.Response.Header} {
+				if header.Get(name) != "" {
+					header.Set(name, Scrubbed)
+				}
+			}
+		}
+	}
+}
+
+// ScrubCookies replaces the value of every cookie in the Cookie and Set-Cookie headers, keeping their names and
+// attributes so that a replayed session still sets the same cookies
+func ScrubCookies() Scrubber {
+	return func(interaction *Interaction) {
+		if cookies := interaction.Request.Header.Values("Cookie"); len(cookies) > 0 {
+			interaction.Request.Header.Del("Cookie")
+			for _, cookie := range cookies {
+				pairs := strings.Split(cookie, ";")
+				for i, pair := range pairs {
+					pairs[i] = scrubCookiePair(pair)
+				}
+				interaction.Request.Header.Add("Cookie", strings.Join(pairs, ";"))
+			}
+		}
+
+		if cookies := interaction.Response.Header.Values("Set-Cookie"); len(cookies) > 0 {
+			interaction.Response.Header.Del("Set-Cookie")
+			for _, cookie := range cookies {
+				// Only the first pair is the cookie, the rest are its attributes
+				pair, attributes, _ := strings.Cut(cookie, ";")
+				scrubbed := scrubCookiePair(pair)
+				if attributes != "" {
+					scrubbed += ";" + attributes
+				}
+				interaction.Response.Header.Add("Set-Cookie", scrubbed)
+			}
+		}
+	}
+}
+
+// scrubCookiePair replaces the value of a name=value pair
+func scrubCookiePair(pair string) string {
+	name, value, found := strings.Cut(pair, "=")
+	if !found || value == "" {
+		return pair
+	}
+	return name + "=" + Scrubbed
+}
+
+// ScrubFormFields replaces the values of the given fields in url-encoded request bodies
+func ScrubFormFields(fields ...string) Scrubber {
+	return func(interaction *Interaction) {
+		if !strings.HasPrefix(interaction.Request.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+			return
+		}
+
+		values, err := url.ParseQuery(interaction.Request.Body)
+		if err != nil {
+			// Rather than risk recording it, drop a body that cannot be scrubbed
+			interaction.Request.Body = Scrubbed
+			return
+		}
+
+		for _, field := range fields {
+			if values.Has(field) {
+				values.Set(field, Scrubbed)
+			}
+		}
+		interaction.Request.Body = values.Encode()
+	}
+}
+
+// ScrubMatches replaces every match of the pattern in request URLs, request and response bodies and Location headers
+// with the result of replace, which is given the match followed by its submatches
+func ScrubMatches(pattern *regexp.Regexp, replace func(groups []string) string) Scrubber {
+	scrub := func(value string) string {
+		return pattern.ReplaceAllStringFunc(value, func(match string) string {
+			return replace(pattern.FindStringSubmatch(match))
+		})
+	}
+
+	return func(interaction *Interaction) {
+		interaction.Request.Url = scrub(interaction.Request.Url)
+		interaction.Request.Body = scrub(interaction.Request.Body)
+		interaction.Response.Body = scrub(interaction.Response.Body)
+		if location := interaction.Response.Header.Get("Location"); location != "" {
+			interaction.Response.Header.Set("Location", scrub(location))
+		}
+	}
+}
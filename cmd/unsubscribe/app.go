@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog"
+	"golang.org/x/time/rate"
+
+	"unsubscribe/cassette"
+	"unsubscribe/config"
+	"unsubscribe/directory"
+	"unsubscribe/metrics"
+	"unsubscribe/ratelimit"
+	"unsubscribe/scla"
+	"unsubscribe/store"
+	"unsubscribe/web"
+)
+
+// Options are the settings that are not part of the config: those only given as flags, the credentials and the transport
+type Options struct {
+	// Username and Password log into the UTSA directory; main reads them from UTSA_USERNAME and UTSA_PASSWORD
+	Username string
+	Password string
+
+	DryRun       bool
+	DryRunOutput string // File each dry-run form is written to, if not empty
+
+	RecordPath string // Cassette file every request and response is recorded to, if not empty
+	ReplayPath string // Cassette file requests are answered from instead of the network, if not empty
+
+	MetricsAddr string // Address /metrics is served on while the command runs, if not empty
+	MetricsDump string // File the final metrics are written to on close ("-" for stdout), if not empty
+
+	// Transport sends every request, defaulting to http.DefaultTransport; tests can point it at an httptest.Server
+	Transport http.RoundTripper
+}
+
+// App holds everything the commands share, built once by main
+type App struct {
+	Config *config.Config
+	Logger zerolog.Logger
+	Store  store.Store
+	Web    *web.Client
+	UTSA   *directory.Client
+	SCLA   *scla.Client
+
+	dryRunOutput io.Closer
+	recorder     *cassette.Recorder
+	recordPath   string
+	metrics      *http.Server
+	metricsDump  string
+}
+
+// NewApp builds the clients on top of the given store, and loads the saved cookies.
+// Each App has its own rate limiters, so Apps with different configs do not affect each other.
+func NewApp(cfg *config.Config, options Options, logger zerolog.Logger, db store.Store) (*App, error) {
+	limits := make(map[string]ratelimit.Limit, len(cfg.Limiters))
+	for domain, limiter := range cfg.Limiters {
+		limits[domain] = ratelimit.Limit{Rate: rate.Limit(limiter.Rate), Burst: limiter.Burst, MaxInFlight: limiter.MaxInFlight}
+	}
+	limiters := ratelimit.NewLimiterRegistry(limits)
+	if cfg.AdaptiveLimits {
+		policy := ratelimit.DefaultAdaptivePolicy
+		limiters.SetAdaptive(&policy)
+	}
+
+	// Each configured policy starts from its domain's default, so a jitter or status codes left out are not lost
+	retries := make(map[string]web.RetryPolicy, len(cfg.Retries))
+	for domain, retry := range cfg.Retries {
+		policy, ok := web.DefaultRetryPolicies[domain]
+		if !ok {
+			policy = web.DefaultRetryPolicy
+		}
+		policy.MaxAttempts, policy.BaseDelay, policy.MaxDelay = retry.MaxAttempts, retry.BaseDelay, retry.MaxDelay
+		if retry.Jitter != nil {
+			policy.Jitter = *retry.Jitter
+		}
+		if len(retry.RetryableStatus) > 0 {
+			policy.RetryableStatus = retry.RetryableStatus
+		}
+		retries[domain] = policy
+	}
+
+	a := &App{Config: cfg, Logger: logger, Store: db, metricsDump: options.MetricsDump}
+
+	// Requests may be recorded, or replayed instead of sent
+	transport := options.Transport
+	if options.RecordPath != "" && options.ReplayPath != "" {
+		return nil, fmt.Errorf("cannot both record and replay a cassette")
+	} else if options.RecordPath != "" {
+		a.recorder = cassette.NewRecorder(transport, cassette.DefaultScrubbers()...)
+		a.recordPath = options.RecordPath
+		transport = a.recorder
+	} else if options.ReplayPath != "" {
+		loaded, err := cassette.Load(options.ReplayPath)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info().Str("path", options.ReplayPath).Int("interactions", len(loaded.Interactions)).Msg("Replaying Cassette")
+		transport = cassette.NewReplayer(loaded)
+	}
+
+	// Setup http client + cookie jar, shared by both clients
+	a.Web = web.NewClientWithTransport(transport)
+	a.Web.Limiters = limiters
+	a.Web.UserAgent = cfg.UserAgent
+	a.Web.RetryPolicies = retries
+	a.UTSA = directory.NewClient(a.Web, db)
+	a.UTSA.BaseUrl = cfg.UTSA.BaseUrl
+	a.UTSA.CachePolicy.DirectoryTTL = cfg.Cache.DirectoryTTL
+	a.UTSA.CachePolicy.EntryTTL = cfg.Cache.EntryTTL
+	a.UTSA.CachePolicy.StaleWhileRevalidate = cfg.Cache.StaleWhileRevalidate
+	a.UTSA.Username = options.Username
+	a.UTSA.Password = options.Password
+	a.SCLA = scla.NewClient(a.Web, db)
+	a.SCLA.Form = cfg.SCLA
+	a.SCLA.DryRun = options.DryRun
+	if options.DryRunOutput != "" {
+		file, err := os.Create(options.DryRunOutput)
+		if err != nil {
+			return nil, err
+		}
+		a.SCLA.DryRunOutput = file
+		a.dryRunOutput = file
+	}
+
+	// Serve metrics for scraping, for as long as the command runs
+	if options.MetricsAddr != "" {
+		listener, err := net.Listen("tcp", options.MetricsAddr)
+		if err != nil {
+			a.closeDryRunOutput()
+			return nil, fmt.Errorf("failed to listen for metrics: %w", err)
+		}
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		a.metrics = &http.Server{Handler: mux}
+		go func() {
+			if err := a.metrics.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Err(err).Msg("Metrics Server Failed")
+			}
+		}()
+		logger.Info().Str("addr", listener.Addr().String()).Msg("Serving Metrics")
+	}
+
+	// Load cookies from db
+	a.UTSA.LoadCookies()
+	return a, nil
+}
+
+// closeDryRunOutput closes the dry-run output file, if one was opened
+func (a *App) closeDryRunOutput() {
+	if a.dryRunOutput != nil {
+		a.dryRunOutput.Close()
+	}
+}
+
+// dumpMetrics writes the final metrics to the dump file, or stdout if it is "-"
+func (a *App) dumpMetrics() error {
+	if a.metricsDump == "-" {
+		return metrics.WriteText(os.Stdout)
+	}
+
+	file, err := os.Create(a.metricsDump)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return metrics.WriteText(file)
+}
+
+// refreshWaitTimeout bounds how long Close waits for background cache refreshes, so an interrupted command still exits promptly
+const refreshWaitTimeout = 5 * time.Second
+
+// Close waits (briefly) for background cache refreshes, closes the dry-run output, saves any recording, dumps the metrics
+// and then stops serving them, and finally closes the store.
+func (a *App) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), refreshWaitTimeout)
+	defer cancel()
+	if err := a.UTSA.WaitRefreshes(ctx); err != nil {
+		a.Logger.Warn().Str("timeout", refreshWaitTimeout.String()).Msg("Abandoning Background Cache Refreshes")
+	}
+
+	a.closeDryRunOutput()
+	if a.recorder != nil {
+		if err := a.recorder.Save(a.recordPath); err != nil {
+			a.Logger.Err(err).Str("path", a.recordPath).Msg("Failed to save cassette")
+		} else {
+			a.Logger.Info().Str("path", a.recordPath).Msg("Cassette Saved")
+		}
+	}
+	if a.metricsDump != "" {
+		if err := a.dumpMetrics(); err != nil {
+			a.Logger.Err(err).Str("path", a.metricsDump).Msg("Failed to dump metrics")
+		}
+	}
+	if a.metrics != nil {
+		a.metrics.Close()
+	}
+	return a.Store.Close()
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"unsubscribe/config"
+	"unsubscribe/scla"
+	"unsubscribe/store"
+	"unsubscribe/web"
+)
+
+// newTestApp builds an App on a MemoryStore, closing it once the test is over
+func newTestApp(t *testing.T, cfg *config.Config, options Options) (*App, *store.MemoryStore) {
+	t.Helper()
+	memory := store.NewMemoryStore()
+	app, err := NewApp(cfg, options, zerolog.Nop(), memory)
+	if err != nil {
+		t.Fatalf("NewApp: %v", err)
+	}
+	t.Cleanup(func() { app.Close() })
+	return app, memory
+}
+
+func TestRetryPoliciesStartFromTheDomainsDefault(t *testing.T) {
+	cfg := config.Default()
+	cfg.Retries["utsa.edu"] = config.Retry{MaxAttempts: 2, BaseDelay: time.Second, MaxDelay: time.Second}
+	cfg.Retries["example.com"] = config.Retry{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: time.Minute, RetryableStatus: []int{503}}
+	app, _ := newTestApp(t, cfg, Options{})
+
+	// Left out, the jitter and status codes are utsa.edu's own, not the global default's
+	utsa := app.Web.RetryPolicy("utsa.edu")
+	want := web.DefaultRetryPolicies["utsa.edu"]
+	if utsa.MaxAttempts != 2 || utsa.Jitter != want.Jitter || !slices.Equal(utsa.RetryableStatus, want.RetryableStatus) {
+		t.Errorf("utsa.edu policy = %+v, expected 2 attempts with the rest of %+v", utsa, want)
+	}
+
+	// A domain without a default of its own starts from the global one
+	example := app.Web.RetryPolicy("example.com")
+	if example.MaxAttempts != 5 || example.Jitter != web.DefaultRetryPolicy.Jitter || !slices.Equal(example.RetryableStatus, []int{503}) {
+		t.Errorf("example.com policy = %+v, expected 5 attempts retrying only 503 with the default jitter", example)
+	}
+}
+
+func TestAppsAreIsolated(t *testing.T) {
+	first, second := config.Default(), config.Default()
+	first.UserAgent = "first"
+	first.Limiters["utsa.edu"] = config.Limiter{Rate: 1, Burst: 1, MaxInFlight: 1}
+	first.AdaptiveLimits = true
+
+	firstApp, firstStore := newTestApp(t, first, Options{Username: "first", Password: "hunter2"})
+	secondApp, secondStore := newTestApp(t, second, Options{Username: "second", Password: "hunter3"})
+
+	if firstApp.Web.Limiters == secondApp.Web.Limiters {
+		t.Fatal("apps share a limiter registry")
+	}
+	if limit := secondApp.Web.Limiters.Limits()["utsa.edu"]; limit.Rate != 2 || limit.MaxInFlight != 3 {
+		t.Errorf("second app's utsa.edu limit = %+v, want the default 2:5:3 untouched by the first app's config", limit)
+	}
+	if firstApp.Web.UserAgent != "first" || secondApp.Web.UserAgent != second.UserAgent {
+		t.Errorf("user agents = %q, %q; want %q, %q", firstApp.Web.UserAgent, secondApp.Web.UserAgent, "first", second.UserAgent)
+	}
+	if firstApp.UTSA.Username != "first" || secondApp.UTSA.Username != "second" || secondApp.UTSA.Password != "hunter3" {
+		t.Errorf("credentials = %s, %s; want each app's own", firstApp.UTSA.Username, secondApp.UTSA.Username)
+	}
+
+	// What one app records is only in its own store
+	if err := firstStore.PutRecord(&scla.Record{Email: "jordan.abbott@my.utsa.edu", Status: scla.StatusUnsubscribed}); err != nil {
+		t.Fatal(err)
+	}
+	if unsubscribed, err := firstApp.SCLA.CheckEmail("jordan.abbott@my.utsa.edu"); err != nil || !unsubscribed {
+		t.Errorf("first app CheckEmail = %t, %v; want true, nil", unsubscribed, err)
+	}
+	if unsubscribed, err := secondApp.SCLA.CheckEmail("jordan.abbott@my.utsa.edu"); err != nil || unsubscribed {
+		t.Errorf("second app CheckEmail = %t, %v; want false, nil", unsubscribed, err)
+	}
+	if _, found, _ := secondStore.GetRecord("jordan.abbott@my.utsa.edu"); found {
+		t.Error("second app's store has the first app's record")
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/samber/lo"
+
+	"unsubscribe/store"
+	"unsubscribe/web"
+)
+
+var cacheCommands = []command{
+	{"list", "List keys, optionally filtered by prefix or family", (*App).runCacheList},
+	{"dump", "Print the values of the given keys (or a prefix) as pretty JSON", (*App).runCacheDump},
+	{"delete", "Delete the given keys, or every key under a prefix", (*App).runCacheDelete},
+	{"clear", "Delete every key in the given families", (*App).runCacheClear},
+	{"stats", "Show the number of keys and their size for each family", (*App).runCacheStats},
+	{"gc", "Run badger's value log garbage collection", (*App).runCacheGC},
+}
+
+func cacheUsage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: cache <command> [command flags]\n\nCommands:\n")
+	for _, cmd := range cacheCommands {
+		fmt.Fprintf(out, "  %-8s %s\n", cmd.name, cmd.description)
+	}
+	fmt.Fprintf(out, "\nKey families: %s\n", strings.Join(store.Families, ", "))
+	fmt.Fprintf(out, "Key scheme: utsa_cookies, directory:<letter>, entry:<id>, <email>\n")
+}
+
+func (a *App) runCache(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		cacheUsage()
+		return fmt.Errorf("no cache command given")
+	}
+
+	cmd, found := lo.Find(cacheCommands, func(cmd command) bool {
+		return cmd.name == args[0]
+	})
+	if !found {
+		cacheUsage()
+		return fmt.Errorf("unknown cache command: %s", args[0])
+	}
+
+	return cmd.run(a, ctx, args[1:])
+}
+
+// badgerStore returns the database as a BadgerStore, as inspection is specific to badger
+func (a *App) badgerStore() (*store.BadgerStore, error) {
+	badgerDb, ok := a.Store.(*store.BadgerStore)
+	if !ok {
+		return nil, fmt.Errorf("cache inspection requires a badger store, not %T", a.Store)
+	}
+	return badgerDb, nil
+}
+
+// validateFamily returns an error if family is not a known key family
+func validateFamily(family string) error {
+	if !lo.Contains(store.Families, family) {
+		return fmt.Errorf("unknown key family: %s", family)
+	}
+	return nil
+}
+
+// expiry describes when a key expires under the configured cache policy: directory pages and entries expire their
+// TTL after they were cached, while the other families are never expired
+func (a *App) expiry(key store.KeyInfo) string {
+	var ttl time.Duration
+	switch key.Family {
+	case store.FamilyDirectory:
+		ttl = a.Config.Cache.DirectoryTTL
+	case store.FamilyEntry:
+		ttl = a.Config.Cache.EntryTTL
+	default:
+		return "never"
+	}
+
+	if ttl == 0 {
+		return "never"
+	} else if key.CachedAt.IsZero() {
+		// Legacy values have no time, so they are always expired
+		return "expired"
+	}
+	return key.CachedAt.Add(ttl).Format(timeFormat)
+}
+
+func (a *App) runCacheList(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("cache list", flag.ExitOnError)
+	prefix := flags.String("prefix", "", "only list keys starting with this prefix")
+	family := flags.String("family", "", "only list keys in this family")
+	flags.Parse(args)
+
+	if *family != "" {
+		if err := validateFamily(*family); err != nil {
+			return err
+		}
+	}
+
+	badgerDb, err := a.badgerStore()
+	if err != nil {
+		return err
+	}
+
+	keys, err := badgerDb.Keys(*prefix)
+	if err != nil {
+		return err
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer out.Flush()
+
+	fmt.Fprintf(out, "KEY\tFAMILY\tSIZE\tEXPIRES\n")
+	for _, key := range keys {
+		if *family != "" && key.Family != *family {
+			continue
+		}
+
+		fmt.Fprintf(out, "%s\t%s\t%s\t%s\n", key.Key, key.Family, web.Bytes(uint64(key.Size)), a.expiry(key))
+	}
+
+	return nil
+}
+
+func (a *App) runCacheDump(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("cache dump", flag.ExitOnError)
+	prefix := flags.String("prefix", "", "dump every key starting with this prefix")
+	flags.Parse(args)
+
+	badgerDb, err := a.badgerStore()
+	if err != nil {
+		return err
+	}
+
+	keys := flags.Args()
+	if *prefix != "" {
+		infos, err := badgerDb.Keys(*prefix)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, lo.Map(infos, func(info store.KeyInfo, _ int) string {
+			return info.Key
+		})...)
+	}
+
+	if len(keys) == 0 {
+		return fmt.Errorf("no keys given")
+	}
+
+	for _, key := range keys {
+		value, found, err := badgerDb.Get(key)
+		if err != nil {
+			return err
+		} else if !found {
+			a.Logger.Warn().Str("key", key).Msg("Key Not Found")
+			continue
+		}
+
+		// Every value is written as JSON (legacy "1" and "0" records included), but a corrupt one is still printed as is
+		var pretty bytes.Buffer
+		if err := json.Indent(&pretty, value, "", "  "); err != nil {
+			pretty.Reset()
+			pretty.Write(value)
+		}
+		fmt.Printf("%s\n%s\n", key, pretty.String())
+	}
+
+	return nil
+}
+
+func (a *App) runCacheDelete(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("cache delete", flag.ExitOnError)
+	prefix := flags.Bool("prefix", false, "treat each argument as a prefix rather than an exact key")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		return fmt.Errorf("no keys given")
+	}
+
+	badgerDb, err := a.badgerStore()
+	if err != nil {
+		return err
+	}
+
+	for _, key := range flags.Args() {
+		if *prefix {
+			// An empty prefix would wipe everything, which is what clear is for
+			if key == "" {
+				return fmt.Errorf("refusing to delete an empty prefix")
+			}
+
+			count, err := badgerDb.DeletePrefix(key)
+			if err != nil {
+				return fmt.Errorf("failed to delete prefix %s: %w", key, err)
+			}
+			a.Logger.Info().Str("prefix", key).Int("count", count).Msg("Prefix Deleted")
+			continue
+		}
+
+		found, err := badgerDb.Delete(key)
+		if err != nil {
+			return fmt.Errorf("failed to delete %s: %w", key, err)
+		} else if !found {
+			a.Logger.Warn().Str("key", key).Msg("Key Not Found")
+		} else {
+			a.Logger.Info().Str("key", key).Msg("Key Deleted")
+		}
+	}
+
+	return nil
+}
+
+func (a *App) runCacheClear(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("cache clear", flag.ExitOnError)
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		return fmt.Errorf("no key families given (%s)", strings.Join(store.Families, ", "))
+	}
+
+	for _, family := range flags.Args() {
+		if err := validateFamily(family); err != nil {
+			return err
+		}
+
+		count, err := a.Store.Clear(family)
+		if err != nil {
+			return fmt.Errorf("failed to clear %s: %w", family, err)
+		}
+		a.Logger.Info().Str("family", family).Int("count", count).Msg("Cache Cleared")
+	}
+
+	return nil
+}
+
+func (a *App) runCacheStats(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("cache stats", flag.ExitOnError)
+	flags.Parse(args)
+
+	badgerDb, err := a.badgerStore()
+	if err != nil {
+		return err
+	}
+
+	stats, err := badgerDb.Stats()
+	if err != nil {
+		return err
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer out.Flush()
+
+	families := lo.Keys(stats)
+	sort.Strings(families)
+
+	var total store.FamilyStats
+	fmt.Fprintf(out, "FAMILY\tKEYS\tSIZE\n")
+	for _, family := range families {
+		familyStats := stats[family]
+		total.Count += familyStats.Count
+		total.Size += familyStats.Size
+		fmt.Fprintf(out, "%s\t%d\t%s\n", family, familyStats.Count, web.Bytes(uint64(familyStats.Size)))
+	}
+	fmt.Fprintf(out, "total\t%d\t%s\n", total.Count, web.Bytes(uint64(total.Size)))
+
+	return nil
+}
+
+func (a *App) runCacheGC(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("cache gc", flag.ExitOnError)
+	ratio := flags.Float64("ratio", 0.5, "rewrite value log files with at least this fraction of discardable data")
+	flags.Parse(args)
+
+	badgerDb, err := a.badgerStore()
+	if err != nil {
+		return err
+	}
+
+	rewrites, err := badgerDb.RunGC(*ratio)
+	if err != nil {
+		return fmt.Errorf("value log gc failed: %w", err)
+	}
+
+	a.Logger.Info().Int("rewrites", rewrites).Msg("Value Log GC Complete")
+	return nil
+}
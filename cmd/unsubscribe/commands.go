@@ -0,0 +1,331 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/samber/lo"
+
+	"unsubscribe/directory"
+	"unsubscribe/scla"
+)
+
+type command struct {
+	name        string
+	description string
+	run         func(a *App, ctx context.Context, args []string) error
+}
+
+var commands = []command{
+	{"login", "Log into the UTSA directory (if required) and save the cookies", (*App).runLogin},
+	{"scrape", "Fetch the A-Z directory pages into the cache", (*App).runScrape},
+	{"entries", "Fetch the full entry of every person in the cached directory pages", (*App).runEntries},
+	{"unsubscribe", "Unsubscribe the given emails, or every email found in the directory", (*App).runUnsubscribe},
+	{"status", "Show login state and cache progress", (*App).runStatus},
+	{"cache", "Maintain the cache database", (*App).runCache},
+	{"run", "Run the whole pipeline: login, scrape, entries & unsubscribe (default)", (*App).runPipeline},
+}
+
+// tool is a command that needs neither the config nor the database, such as the fake servers
+type tool struct {
+	name        string
+	description string
+	run         func(ctx context.Context, args []string) error
+}
+
+var tools = []tool{
+	{"fake-utsa", "Serve a fake UTSA directory of synthetic people, for testing without a network", runFakeUtsa},
+	{"fake-marketo", "Serve a fake Marketo lead capture endpoint, for testing unsubscribes without a network", runFakeMarketo},
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [flags] <command> [command flags]\n\nCommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-12s %s\n", cmd.name, cmd.description)
+	}
+	fmt.Fprintf(out, "\nTools:\n")
+	for _, tool := range tools {
+		fmt.Fprintf(out, "  %-12s %s\n", tool.name, tool.description)
+	}
+	fmt.Fprintf(out, "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+// ensureLogin logs in with the App's credentials, unless the saved cookies are still valid
+func (a *App) ensureLogin(ctx context.Context, force bool) error {
+	if !force {
+		// Check if logged in
+		a.Logger.Debug().Msg("Checking Login State")
+		loggedIn, err := a.UTSA.CheckLoggedIn(ctx)
+		var statusErr directory.UnexpectedStatusError
+		if errors.As(err, &statusErr) {
+			// An odd response to the check is no reason to give up, logging in again may well fix it
+			a.Logger.Warn().Int("code", statusErr.Code).Msg("Unexpected Login Check Response Code")
+		} else if err != nil {
+			return fmt.Errorf("failed to check login state: %w", err)
+		}
+
+		if loggedIn {
+			a.Logger.Info().Msg("Login Not Required")
+			return nil
+		}
+	}
+
+	// Login if required
+	a.Logger.Info().Str("username", a.UTSA.Username).Msg("Attempting Login")
+	err := a.UTSA.Login(ctx, a.UTSA.Username, a.UTSA.Password)
+	if err != nil {
+		return fmt.Errorf("failed to login: %w", err)
+	}
+
+	a.UTSA.SaveCookies()
+	return nil
+}
+
+// parseLetters turns a string such as "abc" into the letters to process, defaulting to A-Z
+func parseLetters(letters string) []rune {
+	if letters == "" {
+		letters = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	}
+	return lo.Uniq([]rune(strings.ToUpper(letters)))
+}
+
+func (a *App) runLogin(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("login", flag.ExitOnError)
+	force := flags.Bool("force", false, "login even if the saved cookies are still valid")
+	flags.Parse(args)
+
+	return a.ensureLogin(ctx, *force)
+}
+
+func (a *App) runScrape(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("scrape", flag.ExitOnError)
+	letters := flags.String("letters", "", "letters to scrape (default A-Z)")
+	flags.Parse(args)
+
+	if err := a.ensureLogin(ctx, false); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	for _, letter := range parseLetters(*letters) {
+		wg.Add(1)
+		go func(letter rune) {
+			defer wg.Done()
+			letterEntries, err := a.UTSA.GetDirectoryCached(ctx, letter)
+			if err != nil {
+				if ctx.Err() == nil {
+					a.Logger.Err(err).Str("letter", string(letter)).Msg("Failed to get directory")
+				}
+				return
+			}
+			a.Logger.Info().Str("letter", string(letter)).Int("count", len(letterEntries)).Msg("Directory Scraped")
+		}(letter)
+	}
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+func (a *App) runEntries(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("entries", flag.ExitOnError)
+	letters := flags.String("letters", "", "letters whose entries should be fetched (default A-Z)")
+	flags.Parse(args)
+
+	if err := a.ensureLogin(ctx, false); err != nil {
+		return err
+	}
+
+	var fetched, cached, failed int
+	interrupted := func() error {
+		a.Logger.Info().Int("fetched", fetched).Int("cached", cached).Int("failed", failed).Msg("Entries Interrupted")
+		return ctx.Err()
+	}
+
+	for _, letter := range parseLetters(*letters) {
+		if ctx.Err() != nil {
+			return interrupted()
+		}
+
+		letterEntries, err := a.UTSA.GetDirectoryCached(ctx, letter)
+		if err != nil {
+			// A letter that failed because of the interrupt is not a failure of its own
+			if ctx.Err() != nil {
+				return interrupted()
+			}
+			a.Logger.Err(err).Str("letter", string(letter)).Msg("Failed to get directory")
+			continue
+		}
+
+		for _, entry := range letterEntries {
+			if ctx.Err() != nil {
+				return interrupted()
+			}
+
+			fullEntry, wasCached, err := a.UTSA.GetFullEntryCached(ctx, entry.Id)
+			if err != nil {
+				if ctx.Err() != nil {
+					return interrupted()
+				}
+				a.Logger.Err(err).Str("name", entry.Name).Msg("Failed to get full entry")
+				failed++
+				continue
+			}
+
+			if wasCached {
+				cached++
+			} else {
+				fetched++
+				a.Logger.Info().Str("name", fullEntry.Name).Str("email", fullEntry.Email).Msg("Entry Fetched")
+			}
+		}
+	}
+
+	a.Logger.Info().Int("fetched", fetched).Int("cached", cached).Int("failed", failed).Msg("Entries Complete")
+	return nil
+}
+
+func (a *App) runUnsubscribe(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("unsubscribe", flag.ExitOnError)
+	letters := flags.String("letters", "", "letters whose emails should be unsubscribed, when no emails are given (default A-Z)")
+	flags.Parse(args)
+
+	emails := flags.Args()
+	if len(emails) == 0 {
+		if err := a.ensureLogin(ctx, false); err != nil {
+			return err
+		}
+
+		for _, letter := range parseLetters(*letters) {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			letterEntries, err := a.UTSA.GetDirectoryCached(ctx, letter)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				a.Logger.Err(err).Str("letter", string(letter)).Msg("Failed to get directory")
+				continue
+			}
+
+			for _, entry := range letterEntries {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+
+				fullEntry, _, err := a.UTSA.GetFullEntryCached(ctx, entry.Id)
+				if err != nil {
+					if ctx.Err() != nil {
+						return ctx.Err()
+					}
+					a.Logger.Err(err).Str("name", entry.Name).Msg("Failed to get full entry")
+					continue
+				}
+
+				if fullEntry.Email != "" {
+					emails = append(emails, fullEntry.Email)
+				}
+			}
+		}
+	}
+
+	var unsubscribed, dryRun, skipped, failed int
+	for _, email := range emails {
+		if ctx.Err() != nil {
+			a.Logger.Info().Int("unsubscribed", unsubscribed).Int("dryRunForms", dryRun).Int("skipped", skipped).Int("failed", failed).
+				Int("remaining", len(emails)-unsubscribed-dryRun-skipped-failed).Msg("Unsubscribe Interrupted")
+			return ctx.Err()
+		}
+
+		outcome, err := a.SCLA.TryUnsubscribe(ctx, email)
+		switch {
+		case err != nil:
+			a.Logger.Err(err).Str("email", email).Str("reason", scla.ErrorType(err)).Msg("Error occurred while trying to unsubscribe email")
+			failed++
+		case outcome == scla.OutcomeSkipped:
+			a.Logger.Debug().Str("email", email).Msg("Email Already Unsubscribed")
+			skipped++
+		case outcome == scla.OutcomeDryRun:
+			// The form itself was already logged as it was built
+			a.Logger.Debug().Str("email", email).Msg("Dry Run Form Counted")
+			dryRun++
+		default:
+			a.Logger.Info().Str("email", email).Msg("Email Unsubscribed")
+			unsubscribed++
+		}
+	}
+
+	a.Logger.Info().Int("unsubscribed", unsubscribed).Int("dryRunForms", dryRun).Int("skipped", skipped).Int("failed", failed).Msg("Unsubscribe Complete")
+	return nil
+}
+
+func (a *App) runStatus(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	offline := flags.Bool("offline", false, "skip checking whether the saved login is still valid")
+	flags.Parse(args)
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer out.Flush()
+
+	if !*offline {
+		loggedIn, err := a.UTSA.CheckLoggedIn(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to check login state: %w", err)
+		}
+		fmt.Fprintf(out, "Logged In\t%t\n", loggedIn)
+	}
+
+	// Walk only what is already cached, never fetching anything
+	var letters, people, entries, emails, attempts int
+	statuses := make(map[scla.Status]int)
+	for _, letter := range parseLetters("") {
+		letterEntries, _, cached, err := a.Store.GetDirectory(letter)
+		if err != nil {
+			return err
+		} else if !cached {
+			continue
+		}
+		letters++
+		people += len(letterEntries)
+
+		for _, entry := range letterEntries {
+			fullEntry, _, cached, err := a.Store.GetEntry(entry.Id)
+			if err != nil {
+				return err
+			} else if !cached {
+				continue
+			}
+			entries++
+
+			if fullEntry.Email == "" {
+				continue
+			}
+			emails++
+
+			record, err := a.SCLA.GetRecord(fullEntry.Email)
+			if err != nil {
+				return err
+			}
+			statuses[record.Status]++
+			attempts += record.Attempts
+		}
+	}
+
+	fmt.Fprintf(out, "Directory Pages\t%d/26\n", letters)
+	fmt.Fprintf(out, "Entries\t%d/%d\n", entries, people)
+	fmt.Fprintf(out, "Unsubscribed\t%d/%d\n", statuses[scla.StatusUnsubscribed], emails)
+	fmt.Fprintf(out, "Rejected\t%d\n", statuses[scla.StatusRejected])
+	fmt.Fprintf(out, "Failed\t%d\n", statuses[scla.StatusFailed])
+	fmt.Fprintf(out, "Pending\t%d\n", statuses[scla.StatusPending])
+	fmt.Fprintf(out, "Attempts\t%d\n", attempts)
+	return nil
+}
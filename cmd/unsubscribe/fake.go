@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/samber/lo"
+
+	"unsubscribe/fakemarketo"
+	"unsubscribe/fakeutsa"
+)
+
+// serve serves the handler on the address until the context is cancelled, then shuts down gracefully
+func serve(ctx context.Context, addr string, handler http.Handler) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{Handler: logRequests(handler)}
+	context.AfterFunc(ctx, func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	})
+
+	log.Info().Str("url", "http://"+listener.Addr().String()).Msg("Serving")
+	err = server.Serve(listener)
+	if errors.Is(err, http.ErrServerClosed) {
+		return ctx.Err()
+	}
+	return err
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// logRequests logs each request served by the handler, along with its status code
+func logRequests(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		handler.ServeHTTP(recorder, r)
+		log.Info().Str("method", r.Method).Str("url", r.URL.RequestURI()).Int("code", recorder.code).Msg("Served")
+	})
+}
+
+func runFakeUtsa(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("fake-utsa", flag.ExitOnError)
+	addr := flags.String("addr", "127.0.0.1:8081", "address to listen on")
+	username := flags.String("username", "fake", "the only username accepted")
+	password := flags.String("password", "fake", "the only password accepted")
+	people := flags.Int("people", 500, "number of synthetic people in the directory")
+	seed := flags.Int64("seed", 1, "seed the synthetic people are generated from")
+	flags.Parse(args)
+
+	log.Info().Str("username", *username).Int("people", *people).Int64("seed", *seed).Msg("Starting Fake UTSA Directory")
+	return serve(ctx, *addr, fakeutsa.New(*username, *password, fakeutsa.GeneratePeople(*seed, *people)))
+}
+
+func runFakeMarketo(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("fake-marketo", flag.ExitOnError)
+	addr := flags.String("addr", "127.0.0.1:8082", "address to listen on")
+	behavior := flags.String("behavior", string(fakemarketo.Succeed), fmt.Sprintf("how to respond to submissions: one of %s", strings.Join(lo.Map(fakemarketo.Behaviors, func(behavior fakemarketo.Behavior, _ int) string {
+		return string(behavior)
+	}), ", ")))
+	munchkinId := flags.String("munchkin-id", "", "reject submissions for any other munchkin ID")
+	retryAfter := flags.Duration("retry-after", time.Second, "Retry-After sent with rate limited responses")
+	flags.Parse(args)
+
+	if !lo.Contains(fakemarketo.Behaviors, fakemarketo.Behavior(*behavior)) {
+		return fmt.Errorf("unknown behavior: %s", *behavior)
+	}
+
+	fake := fakemarketo.New(fakemarketo.Behavior(*behavior))
+	fake.MunchkinId = *munchkinId
+	fake.RetryAfter = *retryAfter
+
+	log.Info().Str("behavior", *behavior).Msg("Starting Fake Marketo Lead Capture")
+	return serve(ctx, *addr, fake)
+}
@@ -0,0 +1,128 @@
+// Command unsubscribe logs into the UTSA directory, scrapes every email address and unsubscribes each from the SCLA mailing list.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/joho/godotenv"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/samber/lo"
+
+	"unsubscribe/config"
+	"unsubscribe/store"
+)
+
+// cliFlags are the global flags, given before the command
+type cliFlags struct {
+	level      string
+	configPath string
+	overrides  config.Overrides
+	options    Options
+}
+
+// parseFlags registers the global flags on the flag set and parses args into them
+func parseFlags(flags *flag.FlagSet, args []string) cliFlags {
+	var parsed cliFlags
+	flags.StringVar(&parsed.level, "level", "info", "log level")
+	flags.StringVar(&parsed.configPath, "config", "", "YAML config file (env "+config.EnvName("config")+")")
+	parsed.overrides = config.RegisterFlags(flags)
+
+	flags.BoolVar(&parsed.options.DryRun, "dry-run", false, "build unsubscribe forms without submitting them or recording anything")
+	flags.StringVar(&parsed.options.DryRunOutput, "dry-run-output", "", "file to write each dry-run form to, as lines of JSON")
+
+	flags.StringVar(&parsed.options.RecordPath, "record", "", "cassette file to record every request and response to, scrubbed of secrets and personal data")
+	flags.StringVar(&parsed.options.ReplayPath, "replay", "", "cassette file to answer requests from, instead of the network")
+
+	flags.StringVar(&parsed.options.MetricsAddr, "metrics-addr", "", "address to serve Prometheus metrics on at /metrics while the command runs, e.g. :9090")
+	flags.StringVar(&parsed.options.MetricsDump, "metrics-dump", "", "file to write the final metrics to in the Prometheus text format (- for stdout)")
+
+	flags.Parse(args)
+	return parsed
+}
+
+func main() {
+	// Acquire log level from flag
+	flag.Usage = usage
+	cli := parseFlags(flag.CommandLine, os.Args[1:])
+	parsedLevel, _ := zerolog.ParseLevel(cli.level)
+	zerolog.SetGlobalLevel(parsedLevel)
+
+	log.Logger = zerolog.New(logSplitter{}).With().Timestamp().Logger()
+
+	// Without a subcommand, the whole pipeline is run
+	name := flag.Arg(0)
+	args := []string{}
+	if name == "" {
+		name = "run"
+	} else {
+		args = flag.Args()[1:]
+	}
+
+	// Interrupts cancel the context, letting commands wind down; a second interrupt exits immediately
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	context.AfterFunc(ctx, stop)
+
+	// Tools run on their own, without the config or database
+	if tool, found := lo.Find(tools, func(tool tool) bool { return tool.name == name }); found {
+		exit(name, tool.run(ctx, args))
+		return
+	}
+
+	cmd, found := lo.Find(commands, func(cmd command) bool {
+		return cmd.name == name
+	})
+	if !found {
+		log.Error().Str("command", name).Msg("Unknown Command")
+		usage()
+		os.Exit(2)
+	}
+
+	// Load .env, before anything reads the environment
+	godotenv.Load()
+
+	// Load the config, with the file given by flag taking precedence over the environment's
+	configPath := cli.configPath
+	if configPath == "" {
+		configPath = os.Getenv(config.EnvName("config"))
+	}
+	cfg, err := config.Load(configPath, cli.overrides)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load config")
+	}
+
+	// Initialize Badger db store
+	db, err := store.OpenBadgerStore(cfg.DBPath)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to open database")
+	}
+
+	// Credentials are kept out of the config, so they never end up in a config file
+	cli.options.Username = os.Getenv("UTSA_USERNAME")
+	cli.options.Password = os.Getenv("UTSA_PASSWORD")
+
+	app, err := NewApp(cfg, cli.options, log.Logger, db)
+	if err != nil {
+		db.Close()
+		log.Fatal().Err(err).Msg("Failed to setup")
+	}
+
+	err = cmd.run(app, ctx, args)
+	app.Close()
+	exit(name, err)
+}
+
+// exit logs how the command ended, exiting with a non-zero status if it was interrupted or failed
+func exit(name string, err error) {
+	if errors.Is(err, context.Canceled) {
+		log.Warn().Str("command", name).Msg("Command Interrupted")
+		os.Exit(130)
+	} else if err != nil {
+		log.Fatal().Err(err).Str("command", name).Msg("Command Failed")
+	}
+}
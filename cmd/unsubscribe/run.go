@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/samber/lo"
+
+	"unsubscribe/directory"
+	"unsubscribe/scla"
+)
+
+// runSummary counts the progress of each pipeline stage, so an interrupted run can report what was left undone
+type runSummary struct {
+	letters       atomic.Int64
+	lettersFailed atomic.Int64
+	entries       atomic.Int64
+	entriesFailed atomic.Int64
+	queued        atomic.Int64
+	unsubscribed  atomic.Int64
+	dryRun        atomic.Int64 // Forms built but not submitted, in dry-run mode
+	skipped       atomic.Int64
+	failed        atomic.Int64
+
+	reasonsMu sync.Mutex
+	reasons   map[string]int // Failure counts by scla.ErrorType
+}
+
+// fail counts a failed unsubscribe under the type of error it failed with
+func (s *runSummary) fail(err error) {
+	s.failed.Add(1)
+
+	s.reasonsMu.Lock()
+	defer s.reasonsMu.Unlock()
+	s.reasons[scla.ErrorType(err)]++
+}
+
+func (s *runSummary) log(logger *zerolog.Logger, dryRun bool, interrupted bool) {
+	queued := s.queued.Load()
+	finished := s.unsubscribed.Load() + s.dryRun.Load() + s.skipped.Load() + s.failed.Load()
+
+	s.reasonsMu.Lock()
+	defer s.reasonsMu.Unlock()
+
+	logger.Info().Bool("interrupted", interrupted).Bool("dryRun", dryRun).
+		Int64("letters", s.letters.Load()).Int64("lettersFailed", s.lettersFailed.Load()).Int64("lettersSkipped", 26-s.letters.Load()-s.lettersFailed.Load()).
+		Int64("entries", s.entries.Load()).Int64("entriesFailed", s.entriesFailed.Load()).
+		Int64("unsubscribed", s.unsubscribed.Load()).Int64("dryRunForms", s.dryRun.Load()).Int64("unsubscribeSkipped", s.skipped.Load()).
+		Int64("unsubscribeFailed", s.failed.Load()).Interface("failureReasons", s.reasons).Int64("unsubscribeAbandoned", queued-finished).
+		Msg("Run Summary")
+}
+
+// unsubscribeJob is an email queued for the unsubscribe stage
+type unsubscribeJob struct {
+	email string
+	fake  bool // Fake emails are only decoys, so they are never recorded
+}
+
+// unsubscribeResult is the outcome of an unsubscribeJob
+type unsubscribeResult struct {
+	unsubscribeJob
+	outcome scla.Outcome
+	err     error
+}
+
+func (a *App) runPipeline(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	drainTimeout := flags.Duration("drain-timeout", 30*time.Second, "how long queued unsubscribes may keep running after an interrupt")
+	flags.Parse(args)
+
+	// Queued unsubscribes outlive an interrupt, but only until the drain timeout
+	drainCtx, cancelDrain := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelDrain()
+	context.AfterFunc(ctx, func() {
+		a.Logger.Warn().Str("timeout", drainTimeout.String()).Msg("Interrupted, Draining Queued Unsubscribes")
+		time.AfterFunc(*drainTimeout, cancelDrain)
+	})
+
+	summary := &runSummary{reasons: make(map[string]int)}
+	defer a.UTSA.SaveCookies()
+
+	if err := a.ensureLogin(ctx, false); err != nil {
+		return err
+	}
+
+	// Each stage's input is buffered to its configured size, so memory is bounded and a slow stage holds back the ones before it
+	pipeline := a.Config.Pipeline
+	letters := make(chan rune, pipeline.Directory.Buffer)
+	incompleteEntries := make(chan directory.Entry, pipeline.Detail.Buffer)
+	jobs := make(chan unsubscribeJob, pipeline.Unsubscribe.Buffer)
+	results := make(chan unsubscribeResult)
+
+	go func() {
+		defer close(letters)
+		for letter := 'A'; letter <= 'Z'; letter++ {
+			select {
+			case letters <- letter:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// Get the directory page of each letter
+	runStage(ctx, pipeline.Directory, letters, incompleteEntries, func(letter rune, emit func(directory.Entry) bool) {
+		letterEntries, err := a.UTSA.GetDirectoryCached(ctx, letter)
+		if err != nil {
+			if ctx.Err() == nil {
+				a.Logger.Err(err).Str("letter", string(letter)).Msg("Failed to get directory, skipping letter")
+				summary.lettersFailed.Add(1)
+			}
+			return
+		}
+		summary.letters.Add(1)
+
+		for _, entry := range letterEntries {
+			if !emit(entry) {
+				return
+			}
+		}
+	})
+
+	// Get the full entry of each person, queueing their email (and sometimes a decoy) unless already unsubscribed
+	runStage(ctx, pipeline.Detail, incompleteEntries, jobs, func(entry directory.Entry, emit func(unsubscribeJob) bool) {
+		a.Logger.Debug().Str("name", entry.Name).Msg("Processing Entry")
+
+		fullEntry, cached, err := a.UTSA.GetFullEntryCached(ctx, entry.Id)
+		if err != nil {
+			if ctx.Err() == nil {
+				a.Logger.Err(err).Str("name", entry.Name).Msg("Failed to get full entry, skipping entry")
+				summary.entriesFailed.Add(1)
+			}
+			return
+		}
+		summary.entries.Add(1)
+
+		if fullEntry.Email == "" {
+			a.Logger.Warn().Str("name", fullEntry.Name).Msg("Entry has no email")
+			return
+		}
+
+		if !cached {
+			a.Logger.Info().Str("name", fullEntry.Name).Str("email", fullEntry.Email).Msg("New Email Found")
+		}
+		a.Logger.Debug().Str("name", fullEntry.Name).Str("email", fullEntry.Email).Msg("Entry Processed")
+
+		seen, err := a.SCLA.CheckEmail(fullEntry.Email)
+		if err != nil {
+			a.Logger.Err(err).Str("email", fullEntry.Email).Msg("Unable to Check Email Unsubscription State")
+		}
+		if seen {
+			return
+		}
+
+		if !emit(unsubscribeJob{email: fullEntry.Email}) {
+			return
+		}
+		summary.queued.Add(1)
+
+		// 1/2 chance to unsubscribe fake email
+		if scla.RandBool() && emit(unsubscribeJob{email: scla.FakeEmail(), fake: true}) {
+			summary.queued.Add(1)
+		}
+	})
+
+	// Unsubscribe each queued email; these run on the drain context, so already queued emails are finished after an interrupt
+	runStage(drainCtx, pipeline.Unsubscribe, jobs, results, func(job unsubscribeJob, _ func(unsubscribeResult) bool) {
+		result := unsubscribeResult{unsubscribeJob: job}
+		if job.fake {
+			result.outcome, result.err = a.SCLA.UnsubscribeDecoy(drainCtx, job.email)
+		} else {
+			result.outcome, result.err = a.SCLA.TryUnsubscribe(drainCtx, job.email)
+		}
+
+		// Every result is read below, even after the drain timeout, so one that was already sent or recorded is never dropped
+		results <- result
+	})
+
+	for result := range results {
+		switch {
+		case result.err != nil:
+			a.Logger.Err(result.err).Str("email", result.email).Str("reason", scla.ErrorType(result.err)).Msg("Error occurred while trying to unsubscribe email")
+			summary.fail(result.err)
+		case result.outcome == scla.OutcomeSkipped:
+			a.Logger.Debug().Str("email", result.email).Msg("Email Already Unsubscribed")
+			summary.skipped.Add(1)
+		case result.outcome == scla.OutcomeDryRun:
+			// The form itself was already logged as it was built
+			a.Logger.Debug().Str("email", result.email).Bool("fake", result.fake).Msg("Dry Run Form Counted")
+			summary.dryRun.Add(1)
+		default:
+			a.Logger.Info().Str("email", result.email).Msg(lo.Ternary(!result.fake, "Email Unsubscribed", "Fake Email Unsubscribed"))
+			summary.unsubscribed.Add(1)
+		}
+	}
+
+	summary.log(&a.Logger, a.SCLA.DryRun, ctx.Err() != nil)
+	return ctx.Err()
+}
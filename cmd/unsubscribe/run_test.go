@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"unsubscribe/config"
+	"unsubscribe/fakemarketo"
+	"unsubscribe/fakeutsa"
+	"unsubscribe/scla"
+	"unsubscribe/store"
+)
+
+// newFakeRun starts a fake UTSA directory and Marketo endpoint, returning an App pointed at both
+func newFakeRun(t *testing.T, people []fakeutsa.Person) (*App, *store.MemoryStore, *fakemarketo.Server) {
+	t.Helper()
+	utsa := fakeutsa.New("student", "hunter2", people)
+	utsaServer := httptest.NewServer(utsa)
+	t.Cleanup(utsaServer.Close)
+	marketo := fakemarketo.New(fakemarketo.Succeed)
+	marketoServer := httptest.NewServer(marketo)
+	t.Cleanup(marketoServer.Close)
+
+	// Both fakes are on 127.0.0.1, which need not be rate limited; small stages keep every buffer and worker busy
+	cfg := config.Default()
+	cfg.UTSA.BaseUrl = utsaServer.URL
+	cfg.SCLA.BaseUrl = marketoServer.URL
+	cfg.Limiters["127.0.0.1"] = config.Limiter{Rate: 1000, Burst: 100}
+	cfg.Pipeline = config.Pipeline{
+		Directory:   config.Stage{Workers: 2, Buffer: 1},
+		Detail:      config.Stage{Workers: 3, Buffer: 2},
+		Unsubscribe: config.Stage{Workers: 2, Buffer: 0},
+	}
+
+	app, memory := newTestApp(t, cfg, Options{Username: "student", Password: "hunter2"})
+	return app, memory, marketo
+}
+
+// runPipelineWithin runs the pipeline, failing the test if it does not return in time
+func runPipelineWithin(t *testing.T, app *App, timeout time.Duration) error {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() { done <- app.runPipeline(context.Background(), nil) }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		t.Fatalf("pipeline did not finish within %s", timeout)
+		return nil
+	}
+}
+
+func TestRunPipelineUnsubscribesEveryone(t *testing.T) {
+	people := fakeutsa.GeneratePeople(1, 26*3)
+	app, memory, marketo := newFakeRun(t, people)
+
+	if err := runPipelineWithin(t, app, 30*time.Second); err != nil {
+		t.Fatalf("runPipeline: %v", err)
+	}
+
+	// Every person was unsubscribed exactly once, alongside any number of decoys
+	submitted := make(map[string]int)
+	for _, submission := range marketo.Submissions() {
+		submitted[submission.Email]++
+	}
+	for _, person := range people {
+		email := person.Full.Email
+		if submitted[email] != 1 {
+			t.Errorf("%s was submitted %d times, want once", email, submitted[email])
+		}
+		if record, found, err := memory.GetRecord(scla.NormalizeEmail(email)); err != nil || !found || record.Status != scla.StatusUnsubscribed {
+			t.Errorf("record of %s = %+v, %t, %v; want unsubscribed", email, record, found, err)
+		}
+	}
+	if decoys := len(marketo.Submissions()) - len(people); decoys < 0 || decoys > len(people) {
+		t.Errorf("%d decoys were submitted for %d people", decoys, len(people))
+	}
+
+	// A second run finds everyone already unsubscribed, and sends nothing
+	before := len(marketo.Submissions())
+	if err := runPipelineWithin(t, app, 30*time.Second); err != nil {
+		t.Fatalf("second runPipeline: %v", err)
+	}
+	if sent := len(marketo.Submissions()) - before; sent != 0 {
+		t.Errorf("second run submitted %d forms, want none", sent)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"unsubscribe/config"
+)
+
+// runStage starts the workers of a pipeline stage, each running work on items from in until it is closed, and closes out once
+// every worker is done. The channels' capacities are the stages' buffers, so a full out blocks the stage's workers, which in
+// turn stop draining in, until the next stage catches up.
+//
+// Once ctx is done, work's emit returns false instead of blocking, and the items left in in are drained without being worked on.
+func runStage[In, Out any](ctx context.Context, shape config.Stage, in <-chan In, out chan<- Out, work func(item In, emit func(Out) bool)) {
+	emit := func(item Out) bool {
+		select {
+		case out <- item:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < shape.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range in {
+				if ctx.Err() != nil {
+					continue
+				}
+				work(item, emit)
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"unsubscribe/config"
+)
+
+// blocked is how long a channel operation must wait before it is taken to be blocked
+const blocked = 100 * time.Millisecond
+
+// feed sends count items into in, then closes it
+func feed(in chan<- int, count int) {
+	defer close(in)
+	for i := 0; i < count; i++ {
+		in <- i
+	}
+}
+
+// drain reads out until it is closed, failing the test if it is not closed in time
+func drain[T any](t *testing.T, out <-chan T) []T {
+	t.Helper()
+	var items []T
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case item, ok := <-out:
+			if !ok {
+				return items
+			}
+			items = append(items, item)
+		case <-timeout:
+			t.Fatal("stage output was never closed")
+			return nil
+		}
+	}
+}
+
+func TestStageRunsItsWorkers(t *testing.T) {
+	shape := config.Stage{Workers: 3}
+	in, out := make(chan int), make(chan int, 100)
+
+	var active, peak atomic.Int64
+	release := make(chan struct{})
+	runStage(context.Background(), shape, in, out, func(item int, emit func(int) bool) {
+		now := active.Add(1)
+		for {
+			if seen := peak.Load(); now <= seen || peak.CompareAndSwap(seen, now) {
+				break
+			}
+		}
+		<-release
+		active.Add(-1)
+		emit(item)
+	})
+	go feed(in, 20)
+
+	// Every worker picks up an item, but no more than that while they are all busy
+	deadline := time.Now().Add(5 * time.Second)
+	for active.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(blocked)
+	if actual := active.Load(); actual != 3 {
+		t.Fatalf("%d items in progress, expected one per worker", actual)
+	}
+
+	close(release)
+	if items := drain(t, out); len(items) != 20 {
+		t.Errorf("%d items emitted, expected 20", len(items))
+	}
+	if actual := peak.Load(); actual != 3 {
+		t.Errorf("at most %d items were in progress at once, expected 3", actual)
+	}
+}
+
+func TestStageIsBoundedByItsOutput(t *testing.T) {
+	shape := config.Stage{Workers: 2, Buffer: 3}
+	in, out := make(chan int), make(chan int, shape.Buffer)
+	runStage(context.Background(), shape, in, out, func(item int, emit func(int) bool) {
+		emit(item)
+	})
+
+	// With nothing reading out, the stage takes enough to fill it plus one item per worker blocked on emitting, and no more
+	accepted := 0
+	for accepted < 100 {
+		select {
+		case in <- accepted:
+			accepted++
+			continue
+		case <-time.After(blocked):
+		}
+		break
+	}
+	if expected := shape.Buffer + shape.Workers; accepted != expected {
+		t.Errorf("stage accepted %d items before blocking, expected %d", accepted, expected)
+	}
+	if len(out) != cap(out) {
+		t.Errorf("output holds %d items, expected it full at %d", len(out), cap(out))
+	}
+
+	close(in)
+	if items := drain(t, out); len(items) != accepted {
+		t.Errorf("%d items emitted, expected the %d accepted", len(items), accepted)
+	}
+}
+
+func TestStageSkipsInputOnceCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	in, out := make(chan int), make(chan int)
+	var worked atomic.Int64
+	runStage(ctx, config.Stage{Workers: 2}, in, out, func(item int, emit func(int) bool) {
+		worked.Add(1)
+		emit(item)
+	})
+
+	// The input is still drained, so whatever feeds it is never stuck
+	fed := make(chan struct{})
+	go func() {
+		feed(in, 50)
+		close(fed)
+	}()
+	select {
+	case <-fed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("canceled stage did not drain its input")
+	}
+
+	drain(t, out)
+	if actual := worked.Load(); actual != 0 {
+		t.Errorf("%d items were worked on after the cancel, expected none", actual)
+	}
+}
+
+func TestStageClosesOutputOnceWorkersExit(t *testing.T) {
+	in, out := make(chan int), make(chan int, 10)
+	release := make(chan struct{})
+	var started sync.WaitGroup
+	started.Add(2)
+	runStage(context.Background(), config.Stage{Workers: 2}, in, out, func(item int, emit func(int) bool) {
+		started.Done()
+		<-release
+		emit(item)
+	})
+	feed(in, 2)
+	started.Wait()
+
+	// The input is closed, but the workers are still busy, so the output stays open
+	select {
+	case _, ok := <-out:
+		t.Fatalf("output read (%t) while workers were still running", ok)
+	case <-time.After(blocked):
+	}
+
+	close(release)
+	if items := drain(t, out); len(items) != 2 {
+		t.Errorf("%d items emitted, expected 2", len(items))
+	}
+}
+
+func TestStageCanceledMidStream(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	in, out := make(chan int), make(chan int)
+	var worked atomic.Int64
+	emitted := make(chan bool, 100)
+	runStage(ctx, config.Stage{Workers: 2}, in, out, func(item int, emit func(int) bool) {
+		worked.Add(1)
+		emitted <- emit(item)
+	})
+
+	fed := make(chan struct{})
+	go func() {
+		feed(in, 100)
+		close(fed)
+	}()
+
+	for i := 0; i < 5; i++ {
+		<-out
+	}
+	cancel()
+
+	// Workers blocked on emitting give up rather than waiting for a reader, and the rest of the input is skipped
+	select {
+	case <-fed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("canceled stage did not drain the rest of its input")
+	}
+	late := drain(t, out)
+	close(emitted)
+
+	sent := 0
+	for ok := range emitted {
+		if ok {
+			sent++
+		}
+	}
+	if sent != 5+len(late) {
+		t.Errorf("emit reported %d items sent, but %d were read", sent, 5+len(late))
+	}
+	if actual := worked.Load(); actual >= 100 {
+		t.Errorf("all %d items were worked on despite the cancel", actual)
+	}
+}
@@ -0,0 +1,549 @@
+// Package config loads the tool's settings from defaults, a YAML file, the environment and flags, each overriding the last.
+package config
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"gopkg.in/yaml.v3"
+
+	"unsubscribe/directory"
+	"unsubscribe/ratelimit"
+	"unsubscribe/scla"
+	"unsubscribe/web"
+)
+
+// EnvPrefix is prepended to each setting's name to form its environment variable, e.g. UNSUBSCRIBE_DB
+const EnvPrefix = "UNSUBSCRIBE_"
+
+// Limiter is the token bucket of a single domain, and how many of its requests may be in flight at once (0 for no limit)
+type Limiter struct {
+	Rate        float64 `yaml:"rate"`
+	Burst       int     `yaml:"burst"`
+	MaxInFlight int     `yaml:"max_in_flight"`
+}
+
+// Retry is the retry policy of a single domain: how many attempts a request is given in total (1 disables retrying),
+// the delays retries back off from and are capped at (Retry-After included), the fraction of each delay that is randomized,
+// and the status codes worth retrying. A jitter or status codes left out keep the domain's default.
+type Retry struct {
+	MaxAttempts     int           `yaml:"max_attempts"`
+	BaseDelay       time.Duration `yaml:"base_delay"`
+	MaxDelay        time.Duration `yaml:"max_delay"`
+	Jitter          *float64      `yaml:"jitter"`
+	RetryableStatus []int         `yaml:"retryable_status"`
+}
+
+// Cache decides how long cached directory pages and entries are fresh (0 to never expire),
+// and whether expired ones are served while they are refreshed in the background
+type Cache struct {
+	DirectoryTTL         time.Duration `yaml:"directory_ttl"`
+	EntryTTL             time.Duration `yaml:"entry_ttl"`
+	StaleWhileRevalidate bool          `yaml:"stale_while_revalidate"`
+}
+
+// Stage is the shape of a pipeline stage: how many workers process its items, and how many items may be queued for them
+// before the stage feeding it blocks
+type Stage struct {
+	Workers int `yaml:"workers"`
+	Buffer  int `yaml:"buffer"`
+}
+
+// Pipeline holds the shape of each stage of the run command
+type Pipeline struct {
+	Directory   Stage `yaml:"directory"`   // Fetches the directory page of each letter
+	Detail      Stage `yaml:"detail"`      // Fetches the full entry of each person found in the directory
+	Unsubscribe Stage `yaml:"unsubscribe"` // Unsubscribes each email found in an entry
+}
+
+// namedStage is a stage of a Pipeline, as named in settings and errors
+type namedStage struct {
+	name  string
+	field func(p *Pipeline) *Stage
+}
+
+// stages names each stage of a Pipeline, in the order they are run
+var stages = []namedStage{
+	{"directory", func(p *Pipeline) *Stage { return &p.Directory }},
+	{"detail", func(p *Pipeline) *Stage { return &p.Detail }},
+	{"unsubscribe", func(p *Pipeline) *Stage { return &p.Unsubscribe }},
+}
+
+// UTSA holds the settings of the UTSA directory
+type UTSA struct {
+	BaseUrl string `yaml:"base_url"`
+}
+
+// Config holds every setting that is not specific to a single command
+type Config struct {
+	DBPath    string             `yaml:"db_path"`
+	UserAgent string             `yaml:"user_agent"`
+	UTSA      UTSA               `yaml:"utsa"`
+	SCLA      scla.FormConfig    `yaml:"scla"`
+	Limiters  map[string]Limiter `yaml:"limiters"`
+	Retries   map[string]Retry   `yaml:"retries"`
+
+	Cache Cache `yaml:"cache"`
+
+	// AdaptiveLimits lowers a domain's rate on 429 and 503 responses or rising response times, recovering slowly
+	AdaptiveLimits bool `yaml:"adaptive_limits"`
+
+	Pipeline Pipeline `yaml:"pipeline"`
+}
+
+// Default returns the settings used when nothing overrides them
+func Default() *Config {
+	limiters := make(map[string]Limiter, len(ratelimit.DefaultLimits))
+	for domain, limit := range ratelimit.DefaultLimits {
+		limiters[domain] = Limiter{Rate: float64(limit.Rate), Burst: limit.Burst, MaxInFlight: limit.MaxInFlight}
+	}
+	retries := make(map[string]Retry, len(web.DefaultRetryPolicies))
+	for domain, policy := range web.DefaultRetryPolicies {
+		jitter := policy.Jitter
+		retries[domain] = Retry{
+			MaxAttempts:     policy.MaxAttempts,
+			BaseDelay:       policy.BaseDelay,
+			MaxDelay:        policy.MaxDelay,
+			Jitter:          &jitter,
+			RetryableStatus: slices.Clone(policy.RetryableStatus),
+		}
+	}
+
+	return &Config{
+		DBPath:    "./db/",
+		UserAgent: web.DefaultUserAgent,
+		UTSA:      UTSA{BaseUrl: directory.DefaultBaseUrl},
+		SCLA:      scla.DefaultForm,
+		Limiters:  limiters,
+		Retries:   retries,
+		Cache: Cache{
+			DirectoryTTL:         directory.DefaultCachePolicy.DirectoryTTL,
+			EntryTTL:             directory.DefaultCachePolicy.EntryTTL,
+			StaleWhileRevalidate: directory.DefaultCachePolicy.StaleWhileRevalidate,
+		},
+		// Fetching workers match the in-flight caps of utsa.edu and thescla.org, as any more would only wait on them
+		Pipeline: Pipeline{
+			Directory:   Stage{Workers: 3, Buffer: 26},
+			Detail:      Stage{Workers: 3, Buffer: 500},
+			Unsubscribe: Stage{Workers: 5, Buffer: 100},
+		},
+	}
+}
+
+// setting is a single value that can be given by environment variable or flag
+type setting struct {
+	name  string
+	usage string
+	get   func(c *Config) string
+	set   func(c *Config, value string) error
+
+	boolean bool // Given as a flag on its own, without a value, to set it to true
+}
+
+// stringSetting builds a setting that simply replaces a string field
+func stringSetting(name string, usage string, field func(c *Config) *string) setting {
+	return setting{
+		name:  name,
+		usage: usage,
+		get:   func(c *Config) string { return *field(c) },
+		set: func(c *Config, value string) error {
+			*field(c) = value
+			return nil
+		},
+	}
+}
+
+// durationSetting builds a setting that parses a duration, such as "168h", into a field
+func durationSetting(name string, usage string, field func(c *Config) *time.Duration) setting {
+	return setting{
+		name:  name,
+		usage: usage,
+		get:   func(c *Config) string { return field(c).String() },
+		set: func(c *Config, value string) error {
+			duration, err := time.ParseDuration(value)
+			if err != nil {
+				return err
+			}
+			*field(c) = duration
+			return nil
+		},
+	}
+}
+
+// boolSetting builds a setting that parses a boolean, such as "true" or "1", into a field
+func boolSetting(name string, usage string, field func(c *Config) *bool) setting {
+	return setting{
+		name:    name,
+		usage:   usage,
+		boolean: true,
+		get:     func(c *Config) string { return strconv.FormatBool(*field(c)) },
+		set: func(c *Config, value string) error {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return err
+			}
+			*field(c) = parsed
+			return nil
+		},
+	}
+}
+
+var settings = []setting{
+	stringSetting("db", "path to the badger database directory", func(c *Config) *string { return &c.DBPath }),
+	stringSetting("user-agent", "user agent sent with every request", func(c *Config) *string { return &c.UserAgent }),
+	stringSetting("utsa-url", "base URL of the UTSA directory", func(c *Config) *string { return &c.UTSA.BaseUrl }),
+	stringSetting("scla-url", "base URL of the SCLA's Marketo pages", func(c *Config) *string { return &c.SCLA.BaseUrl }),
+	stringSetting("mkt-tok", "Marketo token of the unsubscribe form", func(c *Config) *string { return &c.SCLA.MktTok }),
+	stringSetting("munchkin-id", "Marketo munchkin ID of the unsubscribe form", func(c *Config) *string { return &c.SCLA.MunchkinId }),
+	stringSetting("form-id", "Marketo form ID", func(c *Config) *string { return &c.SCLA.FormId }),
+	stringSetting("form-vid", "Marketo form version ID", func(c *Config) *string { return &c.SCLA.FormVid }),
+	stringSetting("lp-id", "Marketo landing page ID", func(c *Config) *string { return &c.SCLA.LpId }),
+	stringSetting("sub-id", "Marketo subscription ID", func(c *Config) *string { return &c.SCLA.SubId }),
+	stringSetting("followup-lp-id", "Marketo follow-up landing page ID", func(c *Config) *string { return &c.SCLA.FollowupLpId }),
+	{
+		name:  "limiters",
+		usage: "per-domain rate limits as domain=rate:burst[:max-in-flight], comma separated",
+		get:   func(c *Config) string { return FormatLimiters(c.Limiters) },
+		set: func(c *Config, value string) error {
+			limiters, err := ParseLimiters(value)
+			if err != nil {
+				return err
+			}
+			for domain, limiter := range limiters {
+				c.Limiters[domain] = limiter
+			}
+			return nil
+		},
+	},
+	{
+		name:  "retries",
+		usage: "per-domain retry policies as domain=attempts:base-delay:max-delay[:jitter[:status/status...]], comma separated",
+		get:   func(c *Config) string { return FormatRetries(c.Retries) },
+		set: func(c *Config, value string) error {
+			retries, err := ParseRetries(value)
+			if err != nil {
+				return err
+			}
+			for domain, retry := range retries {
+				c.Retries[domain] = retry
+			}
+			return nil
+		},
+	},
+	boolSetting("adaptive-limits", "back off from the limiters' rates on 429 and 503 responses or rising response times", func(c *Config) *bool { return &c.AdaptiveLimits }),
+	durationSetting("directory-ttl", "how long cached directory pages are fresh (0 to never expire)", func(c *Config) *time.Duration { return &c.Cache.DirectoryTTL }),
+	durationSetting("entry-ttl", "how long cached entries are fresh (0 to never expire)", func(c *Config) *time.Duration { return &c.Cache.EntryTTL }),
+	boolSetting("stale-while-revalidate", "serve expired cache values while refreshing them in the background", func(c *Config) *bool { return &c.Cache.StaleWhileRevalidate }),
+	{
+		name:  "stages",
+		usage: "workers and buffer size of the run command's stages (directory, detail, unsubscribe) as stage=workers:buffer, comma separated",
+		get:   func(c *Config) string { return FormatStages(c.Pipeline) },
+		set: func(c *Config, value string) error {
+			return ParseStages(value, &c.Pipeline)
+		},
+	},
+}
+
+// EnvName returns the environment variable a setting is read from
+func EnvName(name string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// Overrides holds the settings given as flags, keyed by setting name
+type Overrides map[string]string
+
+// RegisterFlags adds a flag for every setting to the flag set, returning the overrides they are parsed into
+func RegisterFlags(flags *flag.FlagSet) Overrides {
+	defaults := Default()
+	overrides := make(Overrides)
+	for _, s := range settings {
+		name := s.name
+		usage := fmt.Sprintf("%s (env %s, default %q)", s.usage, EnvName(name), s.get(defaults))
+		override := func(value string) error {
+			overrides[name] = value
+			return nil
+		}
+		if s.boolean {
+			flags.BoolFunc(name, usage, override)
+		} else {
+			flags.Func(name, usage, override)
+		}
+	}
+	return overrides
+}
+
+// Load builds the config from the defaults, the YAML file at path (if not empty), the environment and then the overrides,
+// returning an error if any of them is invalid or the resulting config does not validate.
+func Load(path string, overrides Overrides) (*Config, error) {
+	config := Default()
+
+	if path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+
+		decoder := yaml.NewDecoder(bytes.NewReader(raw))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(config); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	}
+
+	for _, s := range settings {
+		if value, ok := os.LookupEnv(EnvName(s.name)); ok {
+			if err := s.set(config, value); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", EnvName(s.name), err)
+			}
+		}
+	}
+
+	for _, s := range settings {
+		if value, ok := overrides[s.name]; ok {
+			if err := s.set(config, value); err != nil {
+				return nil, fmt.Errorf("invalid -%s: %w", s.name, err)
+			}
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	return config, nil
+}
+
+// Validate returns an error describing the first invalid setting
+func (c *Config) Validate() error {
+	if c.DBPath == "" {
+		return fmt.Errorf("db path is empty")
+	}
+	if c.UserAgent == "" {
+		return fmt.Errorf("user agent is empty")
+	}
+	if parsed, err := url.ParseRequestURI(c.UTSA.BaseUrl); err != nil || parsed.Host == "" {
+		return fmt.Errorf("invalid utsa base url %q", c.UTSA.BaseUrl)
+	}
+	if err := c.SCLA.Validate(); err != nil {
+		return err
+	}
+
+	for domain, limiter := range c.Limiters {
+		if limiter.Rate <= 0 {
+			return fmt.Errorf("limiter for %s has a non-positive rate", domain)
+		} else if limiter.Burst < 1 {
+			return fmt.Errorf("limiter for %s has a burst below 1", domain)
+		} else if limiter.MaxInFlight < 0 {
+			return fmt.Errorf("limiter for %s has a negative max in flight", domain)
+		}
+	}
+
+	for domain, retry := range c.Retries {
+		if retry.MaxAttempts < 1 {
+			return fmt.Errorf("retry policy for %s has fewer than 1 attempt", domain)
+		} else if retry.BaseDelay < 0 || retry.MaxDelay < 0 {
+			return fmt.Errorf("retry policy for %s has a negative delay", domain)
+		} else if retry.MaxDelay > 0 && retry.MaxDelay < retry.BaseDelay {
+			return fmt.Errorf("retry policy for %s has a max delay below its base delay", domain)
+		} else if retry.Jitter != nil && (*retry.Jitter < 0 || *retry.Jitter > 1) {
+			return fmt.Errorf("retry policy for %s has a jitter outside 0 to 1", domain)
+		}
+		for _, status := range retry.RetryableStatus {
+			if status < 100 || status > 599 {
+				return fmt.Errorf("retry policy for %s has an invalid status code %d", domain, status)
+			}
+		}
+	}
+
+	if c.Cache.DirectoryTTL < 0 {
+		return fmt.Errorf("directory ttl is negative")
+	} else if c.Cache.EntryTTL < 0 {
+		return fmt.Errorf("entry ttl is negative")
+	}
+
+	for _, stage := range stages {
+		shape := stage.field(&c.Pipeline)
+		if shape.Workers < 1 {
+			return fmt.Errorf("%s stage has fewer than 1 worker", stage.name)
+		} else if shape.Buffer < 0 {
+			return fmt.Errorf("%s stage has a negative buffer", stage.name)
+		}
+	}
+
+	return nil
+}
+
+// ParseLimiters parses limits such as "utsa.edu=2:5:3,thescla.org=3:7", where the max in flight is optional
+func ParseLimiters(value string) (map[string]Limiter, error) {
+	limiters := make(map[string]Limiter)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		domain, limit, found := strings.Cut(part, "=")
+		rateValue, burstValue, hasBurst := strings.Cut(limit, ":")
+		burstValue, inFlightValue, hasInFlight := strings.Cut(burstValue, ":")
+		if !found || !hasBurst || domain == "" {
+			return nil, fmt.Errorf("limiter %q is not of the form domain=rate:burst[:max-in-flight]", part)
+		}
+
+		rate, err := strconv.ParseFloat(rateValue, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate for %s: %w", domain, err)
+		}
+		burst, err := strconv.Atoi(burstValue)
+		if err != nil {
+			return nil, fmt.Errorf("invalid burst for %s: %w", domain, err)
+		}
+
+		inFlight := 0
+		if hasInFlight {
+			inFlight, err = strconv.Atoi(inFlightValue)
+			if err != nil {
+				return nil, fmt.Errorf("invalid max in flight for %s: %w", domain, err)
+			}
+		}
+
+		limiters[domain] = Limiter{Rate: rate, Burst: burst, MaxInFlight: inFlight}
+	}
+	return limiters, nil
+}
+
+// FormatLimiters formats limits in the form ParseLimiters accepts
+func FormatLimiters(limiters map[string]Limiter) string {
+	parts := make([]string, 0, len(limiters))
+	for domain, limiter := range limiters {
+		part := fmt.Sprintf("%s=%s:%d", domain, strconv.FormatFloat(limiter.Rate, 'f', -1, 64), limiter.Burst)
+		if limiter.MaxInFlight > 0 {
+			part += fmt.Sprintf(":%d", limiter.MaxInFlight)
+		}
+		parts = append(parts, part)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// ParseRetries parses retry policies such as "utsa.edu=4:2s:1m:0.5,thescla.org=3:1s:30s::429/503", where the jitter and
+// the slash separated status codes are optional, and either may be left empty to keep the domain's default
+func ParseRetries(value string) (map[string]Retry, error) {
+	retries := make(map[string]Retry)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		domain, policy, found := strings.Cut(part, "=")
+		fields := strings.Split(policy, ":")
+		if !found || len(fields) < 3 || len(fields) > 5 || domain == "" {
+			return nil, fmt.Errorf("retry policy %q is not of the form domain=attempts:base-delay:max-delay[:jitter[:status/status...]]", part)
+		}
+
+		attempts, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid attempts for %s: %w", domain, err)
+		}
+		baseDelay, err := time.ParseDuration(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid base delay for %s: %w", domain, err)
+		}
+		maxDelay, err := time.ParseDuration(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid max delay for %s: %w", domain, err)
+		}
+
+		retry := Retry{MaxAttempts: attempts, BaseDelay: baseDelay, MaxDelay: maxDelay}
+		if len(fields) > 3 && fields[3] != "" {
+			jitter, err := strconv.ParseFloat(fields[3], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid jitter for %s: %w", domain, err)
+			}
+			retry.Jitter = &jitter
+		}
+		if len(fields) > 4 && fields[4] != "" {
+			for _, statusValue := range strings.Split(fields[4], "/") {
+				status, err := strconv.Atoi(statusValue)
+				if err != nil {
+					return nil, fmt.Errorf("invalid retryable status for %s: %w", domain, err)
+				}
+				retry.RetryableStatus = append(retry.RetryableStatus, status)
+			}
+		}
+
+		retries[domain] = retry
+	}
+	return retries, nil
+}
+
+// FormatRetries formats retry policies in the form ParseRetries accepts
+func FormatRetries(retries map[string]Retry) string {
+	parts := make([]string, 0, len(retries))
+	for domain, retry := range retries {
+		part := fmt.Sprintf("%s=%d:%s:%s", domain, retry.MaxAttempts, retry.BaseDelay, retry.MaxDelay)
+		if retry.Jitter != nil || len(retry.RetryableStatus) > 0 {
+			part += ":"
+			if retry.Jitter != nil {
+				part += strconv.FormatFloat(*retry.Jitter, 'f', -1, 64)
+			}
+		}
+		if len(retry.RetryableStatus) > 0 {
+			part += ":" + strings.Join(lo.Map(retry.RetryableStatus, func(status int, _ int) string { return strconv.Itoa(status) }), "/")
+		}
+		parts = append(parts, part)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// ParseStages parses stage shapes such as "directory=3:26,unsubscribe=5:100" into the pipeline, leaving stages not given as they are
+func ParseStages(value string, pipeline *Pipeline) error {
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, shape, found := strings.Cut(part, "=")
+		workersValue, bufferValue, hasBuffer := strings.Cut(shape, ":")
+		if !found || !hasBuffer {
+			return fmt.Errorf("stage %q is not of the form stage=workers:buffer", part)
+		}
+
+		stage, known := lo.Find(stages, func(stage namedStage) bool { return stage.name == name })
+		if !known {
+			return fmt.Errorf("unknown stage %q", name)
+		}
+
+		workers, err := strconv.Atoi(workersValue)
+		if err != nil {
+			return fmt.Errorf("invalid workers for %s: %w", name, err)
+		}
+		buffer, err := strconv.Atoi(bufferValue)
+		if err != nil {
+			return fmt.Errorf("invalid buffer for %s: %w", name, err)
+		}
+
+		*stage.field(pipeline) = Stage{Workers: workers, Buffer: buffer}
+	}
+	return nil
+}
+
+// FormatStages formats the stage shapes in the form ParseStages accepts
+func FormatStages(pipeline Pipeline) string {
+	parts := make([]string, 0, len(stages))
+	for _, stage := range stages {
+		shape := stage.field(&pipeline)
+		parts = append(parts, fmt.Sprintf("%s=%d:%d", stage.name, shape.Workers, shape.Buffer))
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,261 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfigFile writes a YAML config file into a temporary directory, returning its path
+func writeConfigFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	const file = `
+db_path: ./file-db/
+user_agent: file-agent
+limiters:
+  utsa.edu: {rate: 1, burst: 2, max_in_flight: 1}
+cache:
+  entry_ttl: 24h
+`
+
+	cases := []struct {
+		name      string
+		file      string
+		env       map[string]string
+		overrides Overrides
+		check     func(c *Config) string
+	}{
+		{"defaults", "", nil, nil, func(c *Config) string {
+			return expect(c.DBPath == "./db/" && c.Limiters["utsa.edu"] == Limiter{Rate: 2, Burst: 5, MaxInFlight: 3}, "default db path and limiter")
+		}},
+		{"file over defaults", file, nil, nil, func(c *Config) string {
+			return expect(c.DBPath == "./file-db/" && c.UserAgent == "file-agent" && c.Cache.EntryTTL == 24*time.Hour &&
+				c.Limiters["utsa.edu"] == Limiter{Rate: 1, Burst: 2, MaxInFlight: 1}, "the file's settings")
+		}},
+		{"file keeps unmentioned defaults", file, nil, nil, func(c *Config) string {
+			return expect(c.Cache.DirectoryTTL == Default().Cache.DirectoryTTL && c.Limiters["thescla.org"] == Default().Limiters["thescla.org"],
+				"default directory ttl and thescla.org limiter")
+		}},
+		{"env over file", file, map[string]string{"UNSUBSCRIBE_DB": "./env-db/", "UNSUBSCRIBE_ENTRY_TTL": "1h"}, nil, func(c *Config) string {
+			return expect(c.DBPath == "./env-db/" && c.Cache.EntryTTL == time.Hour && c.UserAgent == "file-agent", "env db path and ttl over the file's")
+		}},
+		{"env merges limiters", file, map[string]string{"UNSUBSCRIBE_LIMITERS": "example.com=4:4"}, nil, func(c *Config) string {
+			return expect(c.Limiters["example.com"] == Limiter{Rate: 4, Burst: 4} && c.Limiters["utsa.edu"] == Limiter{Rate: 1, Burst: 2, MaxInFlight: 1},
+				"env limiter alongside the file's")
+		}},
+		{"flags over env", file, map[string]string{"UNSUBSCRIBE_DB": "./env-db/"}, Overrides{"db": "./flag-db/", "adaptive-limits": "true"}, func(c *Config) string {
+			return expect(c.DBPath == "./flag-db/" && c.AdaptiveLimits, "flag db path and adaptive limits")
+		}},
+		{"flags over file", file, nil, Overrides{"limiters": "utsa.edu=9:9:9", "stages": "detail=1:0"}, func(c *Config) string {
+			return expect(c.Limiters["utsa.edu"] == Limiter{Rate: 9, Burst: 9, MaxInFlight: 9} &&
+				c.Pipeline.Detail == Stage{Workers: 1} && c.Pipeline.Directory == Default().Pipeline.Directory, "flag limiter and detail stage")
+		}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			for name, value := range c.env {
+				t.Setenv(name, value)
+			}
+			path := ""
+			if c.file != "" {
+				path = writeConfigFile(t, c.file)
+			}
+
+			config, err := Load(path, c.overrides)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if missing := c.check(config); missing != "" {
+				t.Errorf("config = %+v, expected %s", config, missing)
+			}
+		})
+	}
+}
+
+// expect returns what was expected of a config if it was not met, or nothing if it was
+func expect(ok bool, expected string) string {
+	if ok {
+		return ""
+	}
+	return expected
+}
+
+func TestLoadRejects(t *testing.T) {
+	cases := []struct {
+		name      string
+		file      string
+		env       map[string]string
+		overrides Overrides
+		want      string
+	}{
+		{"unknown field", "db_paht: ./db/\n", nil, nil, "field db_paht not found"},
+		{"unknown nested field", "cache:\n  ttl: 1h\n", nil, nil, "field ttl not found"},
+		{"malformed file", "limiters: [\n", nil, nil, "failed to parse config file"},
+		{"invalid env", "", map[string]string{"UNSUBSCRIBE_DIRECTORY_TTL": "weekly"}, nil, "invalid UNSUBSCRIBE_DIRECTORY_TTL"},
+		{"invalid env bool", "", map[string]string{"UNSUBSCRIBE_ADAPTIVE_LIMITS": "sometimes"}, nil, "invalid UNSUBSCRIBE_ADAPTIVE_LIMITS"},
+		{"invalid flag", "", nil, Overrides{"retries": "utsa.edu=4"}, "invalid -retries"},
+		{"invalid result", "pipeline:\n  detail: {workers: 0}\n", nil, nil, "invalid config: detail stage has fewer than 1 worker"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			for name, value := range c.env {
+				t.Setenv(name, value)
+			}
+			path := ""
+			if c.file != "" {
+				path = writeConfigFile(t, c.file)
+			}
+
+			if _, err := Load(path, c.overrides); err == nil || !strings.Contains(err.Error(), c.want) {
+				t.Errorf("Load error = %v, expected one containing %q", err, c.want)
+			}
+		})
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml"), nil); err == nil || !strings.Contains(err.Error(), "failed to read config file") {
+		t.Errorf("Load of a missing file = %v, expected a read error", err)
+	}
+}
+
+// jitter returns a pointer to a retry jitter, as a config leaving it out has none
+func jitter(value float64) *float64 {
+	return &value
+}
+
+func TestValidate(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Fatalf("default config is invalid: %v", err)
+	}
+
+	cases := []struct {
+		name   string
+		modify func(c *Config)
+		want   string
+	}{
+		{"empty db path", func(c *Config) { c.DBPath = "" }, "db path is empty"},
+		{"empty user agent", func(c *Config) { c.UserAgent = "" }, "user agent is empty"},
+		{"utsa url without host", func(c *Config) { c.UTSA.BaseUrl = "/directory" }, "invalid utsa base url"},
+		{"invalid utsa url", func(c *Config) { c.UTSA.BaseUrl = "utsa.edu" }, "invalid utsa base url"},
+		{"invalid scla form", func(c *Config) { c.SCLA.FormId = "" }, "missing form parameters: form_id"},
+		{"zero rate", func(c *Config) { c.Limiters["utsa.edu"] = Limiter{Rate: 0, Burst: 1} }, "limiter for utsa.edu has a non-positive rate"},
+		{"zero burst", func(c *Config) { c.Limiters["utsa.edu"] = Limiter{Rate: 1, Burst: 0} }, "limiter for utsa.edu has a burst below 1"},
+		{"negative max in flight", func(c *Config) { c.Limiters["utsa.edu"] = Limiter{Rate: 1, Burst: 1, MaxInFlight: -1} }, "negative max in flight"},
+		{"no attempts", func(c *Config) { c.Retries["utsa.edu"] = Retry{MaxAttempts: 0} }, "retry policy for utsa.edu has fewer than 1 attempt"},
+		{"negative base delay", func(c *Config) { c.Retries["utsa.edu"] = Retry{MaxAttempts: 1, BaseDelay: -time.Second} }, "negative delay"},
+		{"negative max delay", func(c *Config) { c.Retries["utsa.edu"] = Retry{MaxAttempts: 1, MaxDelay: -time.Second} }, "negative delay"},
+		{"max below base delay", func(c *Config) {
+			c.Retries["utsa.edu"] = Retry{MaxAttempts: 1, BaseDelay: time.Minute, MaxDelay: time.Second}
+		}, "max delay below its base delay"},
+		{"negative jitter", func(c *Config) { c.Retries["utsa.edu"] = Retry{MaxAttempts: 1, Jitter: jitter(-0.1)} }, "jitter outside 0 to 1"},
+		{"jitter above 1", func(c *Config) { c.Retries["utsa.edu"] = Retry{MaxAttempts: 1, Jitter: jitter(1.5)} }, "jitter outside 0 to 1"},
+		{"invalid status", func(c *Config) { c.Retries["utsa.edu"] = Retry{MaxAttempts: 1, RetryableStatus: []int{429, 5030}} }, "invalid status code 5030"},
+		{"negative directory ttl", func(c *Config) { c.Cache.DirectoryTTL = -time.Hour }, "directory ttl is negative"},
+		{"negative entry ttl", func(c *Config) { c.Cache.EntryTTL = -time.Hour }, "entry ttl is negative"},
+		{"no workers", func(c *Config) { c.Pipeline.Unsubscribe.Workers = 0 }, "unsubscribe stage has fewer than 1 worker"},
+		{"negative buffer", func(c *Config) { c.Pipeline.Directory.Buffer = -1 }, "directory stage has a negative buffer"},
+	}
+
+	for _, c := range cases {
+		config := Default()
+		c.modify(config)
+		if err := config.Validate(); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: Validate = %v, expected an error containing %q", c.name, err, c.want)
+		}
+	}
+
+	// An unlimited max delay, zero ttls and an unbuffered stage are all allowed
+	config := Default()
+	config.Retries["utsa.edu"] = Retry{MaxAttempts: 1, BaseDelay: time.Minute}
+	config.Cache = Cache{}
+	config.Pipeline.Unsubscribe.Buffer = 0
+	if err := config.Validate(); err != nil {
+		t.Errorf("Validate of edge values = %v, expected nil", err)
+	}
+}
+
+func TestParseLimiters(t *testing.T) {
+	limiters, err := ParseLimiters(" utsa.edu=2:5:3, thescla.org=0.5:7,")
+	want := map[string]Limiter{"utsa.edu": {Rate: 2, Burst: 5, MaxInFlight: 3}, "thescla.org": {Rate: 0.5, Burst: 7}}
+	if err != nil || !reflect.DeepEqual(limiters, want) {
+		t.Fatalf("ParseLimiters = %+v, %v; expected %+v", limiters, err, want)
+	}
+	if formatted := FormatLimiters(limiters); formatted != "thescla.org=0.5:7,utsa.edu=2:5:3" {
+		t.Errorf("FormatLimiters = %q", formatted)
+	}
+	if roundTrip, err := ParseLimiters(FormatLimiters(Default().Limiters)); err != nil || !reflect.DeepEqual(roundTrip, Default().Limiters) {
+		t.Errorf("default limiters round trip to %+v, %v", roundTrip, err)
+	}
+
+	for _, value := range []string{"utsa.edu", "utsa.edu=2", "=2:5", "utsa.edu=fast:5", "utsa.edu=2:many", "utsa.edu=2:5:all", "utsa.edu=2:5:3:1"} {
+		if _, err := ParseLimiters(value); err == nil {
+			t.Errorf("ParseLimiters(%q) succeeded, expected an error", value)
+		}
+	}
+}
+
+func TestParseRetries(t *testing.T) {
+	retries, err := ParseRetries("utsa.edu=4:2s:1m, thescla.org=1:0s:0s:0, example.com=3:1s:30s::429/503")
+	want := map[string]Retry{
+		"utsa.edu":    {MaxAttempts: 4, BaseDelay: 2 * time.Second, MaxDelay: time.Minute},
+		"thescla.org": {MaxAttempts: 1, Jitter: jitter(0)},
+		"example.com": {MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: 30 * time.Second, RetryableStatus: []int{429, 503}},
+	}
+	if err != nil || !reflect.DeepEqual(retries, want) {
+		t.Fatalf("ParseRetries = %+v, %v; expected %+v", retries, err, want)
+	}
+	if roundTrip, err := ParseRetries(FormatRetries(retries)); err != nil || !reflect.DeepEqual(roundTrip, retries) {
+		t.Errorf("retries round trip to %+v, %v", roundTrip, err)
+	}
+	if roundTrip, err := ParseRetries(FormatRetries(Default().Retries)); err != nil || !reflect.DeepEqual(roundTrip, Default().Retries) {
+		t.Errorf("default retries round trip to %+v, %v", roundTrip, err)
+	}
+
+	if formatted := FormatRetries(retries); formatted != "example.com=3:1s:30s::429/503,thescla.org=1:0s:0s:0,utsa.edu=4:2s:1m0s" {
+		t.Errorf("FormatRetries = %q", formatted)
+	}
+
+	for _, value := range []string{
+		"utsa.edu", "utsa.edu=4:2s", "=4:2s:1m", "utsa.edu=four:2s:1m", "utsa.edu=4:2:1m", "utsa.edu=4:2s:later",
+		"utsa.edu=4:2s:1m:half", "utsa.edu=4:2s:1m:0.5:soon", "utsa.edu=4:2s:1m:0.5:429/", "utsa.edu=4:2s:1m:0.5:429:1",
+	} {
+		if _, err := ParseRetries(value); err == nil {
+			t.Errorf("ParseRetries(%q) succeeded, expected an error", value)
+		}
+	}
+}
+
+func TestParseStages(t *testing.T) {
+	pipeline := Default().Pipeline
+	if err := ParseStages("detail=8:1000, unsubscribe=2:0", &pipeline); err != nil {
+		t.Fatal(err)
+	}
+	want := Pipeline{Directory: Default().Pipeline.Directory, Detail: Stage{Workers: 8, Buffer: 1000}, Unsubscribe: Stage{Workers: 2}}
+	if pipeline != want {
+		t.Errorf("ParseStages = %+v, expected %+v with the directory stage untouched", pipeline, want)
+	}
+
+	var roundTrip Pipeline
+	if err := ParseStages(FormatStages(pipeline), &roundTrip); err != nil || roundTrip != pipeline {
+		t.Errorf("stages round trip to %+v, %v", roundTrip, err)
+	}
+
+	for _, value := range []string{"detail", "detail=8", "scrape=1:1", "detail=many:1", "detail=8:big"} {
+		pipeline := Default().Pipeline
+		if err := ParseStages(value, &pipeline); err == nil {
+			t.Errorf("ParseStages(%q) succeeded, expected an error", value)
+		}
+	}
+}
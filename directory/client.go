@@ -0,0 +1,93 @@
+// Package directory logs into the UTSA directory and scrapes its A-Z listings and individual person pages.
+package directory
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/samber/lo"
+
+	"unsubscribe/web"
+)
+
+// Cache is the persistence the directory client needs: login cookies, directory pages and full entries.
+// Getters return false when the value is not cached, along with the time the value was cached otherwise.
+type Cache interface {
+	LoadCookies() ([]http.Cookie, error)
+	SaveCookies(cookies []http.Cookie) error
+
+	GetDirectory(letter rune) (entries []Entry, cachedAt time.Time, found bool, err error)
+	SetDirectory(letter rune, entries []Entry) error
+
+	GetEntry(id string) (entry *FullEntry, cachedAt time.Time, found bool, err error)
+	SetEntry(id string, entry *FullEntry) error
+}
+
+// Client scrapes the UTSA directory using the given web client, caching results in the given cache
+type Client struct {
+	web   *web.Client
+	cache Cache
+
+	// BaseUrl is the scheme and host the directory is served from, defaulting to DefaultBaseUrl
+	BaseUrl string
+	// CachePolicy decides when cached values are refreshed, defaulting to DefaultCachePolicy
+	CachePolicy CachePolicy
+	// Username and Password are used to log in again when the session expires mid-run; without them, expiry is left to the caller
+	Username string
+	Password string
+
+	refreshing sync.Map // Keys currently being revalidated in the background
+	refreshes  sync.WaitGroup
+
+	sessionMu  sync.Mutex // Held while logging in again, see relogin
+	generation int        // Times the session has been renewed
+	reloginErr error      // Why logging in again failed, if it did
+}
+
+// DefaultBaseUrl is where the UTSA directory lives
+const DefaultBaseUrl = "https://www.utsa.edu"
+
+// NewClient creates a directory Client
+func NewClient(webClient *web.Client, cache Cache) *Client {
+	return &Client{web: webClient, cache: cache, BaseUrl: DefaultBaseUrl, CachePolicy: DefaultCachePolicy}
+}
+
+// SaveCookies persists the utsa.edu cookies currently in the jar
+func (c *Client) SaveCookies() {
+	// Get cookies for UTSA.EDU
+	utsaUrl, _ := url.Parse(c.BaseUrl)
+	utsaCookies := lo.Map(c.web.HTTP.Jar.Cookies(utsaUrl), func(cookiePointer *http.Cookie, _ int) http.Cookie {
+		return *cookiePointer
+	})
+
+	log.Info().Interface("cookies", lo.Map(utsaCookies, func(cookie http.Cookie, _ int) string {
+		return cookie.Name
+	})).Msg("Saving Cookies")
+
+	err := c.cache.SaveCookies(utsaCookies)
+	if err != nil {
+		log.Err(err).Msg("Failed to save marshalled cookies")
+	}
+}
+
+// LoadCookies places previously persisted utsa.edu cookies into the jar
+func (c *Client) LoadCookies() {
+	// Load cookies from DB
+	cookies, err := c.cache.LoadCookies()
+	if err != nil {
+		log.Err(err).Msg("Failed to load marshalled cookies")
+	}
+
+	// Place cookies in the jar
+	utsaUrl, _ := url.Parse(c.BaseUrl)
+	c.web.HTTP.Jar.SetCookies(utsaUrl, lo.Map(cookies, func(cookie http.Cookie, _ int) *http.Cookie {
+		return &cookie
+	}))
+
+	log.Info().Interface("cookies", lo.Map(cookies, func(cookie http.Cookie, _ int) string {
+		return cookie.Name
+	})).Msg("Cookies Loaded")
+}
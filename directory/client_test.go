@@ -0,0 +1,287 @@
+package directory_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"unsubscribe/directory"
+	"unsubscribe/fakeutsa"
+	"unsubscribe/ratelimit"
+	"unsubscribe/store"
+	"unsubscribe/web"
+)
+
+// newFakeDirectory starts a fake UTSA directory, returning it along with a client pointed at it
+func newFakeDirectory(t *testing.T, people []fakeutsa.Person) (*fakeutsa.Server, *directory.Client) {
+	fake, client, _ := newFakeDirectoryWithStore(t, people)
+	return fake, client
+}
+
+// newFakeDirectoryWithStore is newFakeDirectory, also returning the client's store
+func newFakeDirectoryWithStore(t *testing.T, people []fakeutsa.Person) (*fakeutsa.Server, *directory.Client, *store.MemoryStore) {
+	t.Helper()
+
+	fake := fakeutsa.New("student", "hunter2", people)
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	memory := store.NewMemoryStore()
+	// Requests to the fake server need not be rate limited
+	webClient := web.NewClientWithTransport(server.Client().Transport)
+	webClient.Limiters.SetLimit(server.URL, ratelimit.Limit{Rate: rate.Inf, Burst: 1})
+
+	client := directory.NewClient(webClient, memory)
+	client.BaseUrl = server.URL
+	return fake, client, memory
+}
+
+func TestLoginScrapeDetail(t *testing.T) {
+	ctx := context.Background()
+	people := fakeutsa.GeneratePeople(1, 60)
+	fake, client := newFakeDirectory(t, people)
+
+	loggedIn, err := client.CheckLoggedIn(ctx)
+	if err != nil || loggedIn {
+		t.Fatalf("CheckLoggedIn before login = %t, %v; want false, nil", loggedIn, err)
+	}
+
+	if err := client.Login(ctx, fake.Username, fake.Password); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	loggedIn, err = client.CheckLoggedIn(ctx)
+	if err != nil || !loggedIn {
+		t.Fatalf("CheckLoggedIn after login = %t, %v; want true, nil", loggedIn, err)
+	}
+
+	// Every person must be found on their letter's page, and their detail page must match
+	scraped := 0
+	for _, letter := range []rune("ABCXZ") {
+		entries, err := client.GetDirectoryCached(ctx, letter)
+		if err != nil {
+			t.Fatalf("GetDirectoryCached(%c): %v", letter, err)
+		}
+
+		for _, entry := range entries {
+			person := findPerson(t, people, entry.Id)
+			if !reflect.DeepEqual(entry, person.Entry) {
+				t.Errorf("directory entry = %+v, want %+v", entry, person.Entry)
+			}
+
+			full, cached, err := client.GetFullEntryCached(ctx, entry.Id)
+			if err != nil {
+				t.Fatalf("GetFullEntryCached(%s): %v", entry.Id, err)
+			} else if cached {
+				t.Errorf("GetFullEntryCached(%s) was cached on first fetch", entry.Id)
+			}
+			if !reflect.DeepEqual(*full, person.Full) {
+				t.Errorf("full entry = %+v, want %+v", *full, person.Full)
+			}
+			scraped++
+		}
+	}
+
+	if scraped == 0 {
+		t.Fatal("no entries were scraped")
+	}
+
+	// A second pass is served from the cache, even once the session is gone
+	fake.ExpireSessions()
+	entries, err := client.GetDirectoryCached(ctx, 'A')
+	if err != nil || len(entries) == 0 {
+		t.Fatalf("cached GetDirectoryCached('A') = %d entries, %v", len(entries), err)
+	}
+	if _, cached, err := client.GetFullEntryCached(ctx, entries[0].Id); err != nil || !cached {
+		t.Fatalf("cached GetFullEntryCached = cached %t, %v; want true, nil", cached, err)
+	}
+}
+
+func TestLoginWrongPassword(t *testing.T) {
+	fake, client := newFakeDirectory(t, fakeutsa.GeneratePeople(1, 5))
+
+	err := client.Login(context.Background(), fake.Username, "wrong")
+	var loginErr directory.LoginError
+	if !errors.As(err, &loginErr) || loginErr.Step != "submit" {
+		t.Fatalf("Login with the wrong password = %v, want a LoginError at the submit step", err)
+	}
+	if fake.Logins() != 0 {
+		t.Errorf("fake recorded %d logins, want 0", fake.Logins())
+	}
+}
+
+func TestExpiredSessionIsNotLoggedIn(t *testing.T) {
+	ctx := context.Background()
+	fake, client := newFakeDirectory(t, fakeutsa.GeneratePeople(1, 5))
+
+	if err := client.Login(ctx, fake.Username, fake.Password); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	fake.ExpireSessions()
+
+	loggedIn, err := client.CheckLoggedIn(ctx)
+	if err != nil || loggedIn {
+		t.Fatalf("CheckLoggedIn with an expired session = %t, %v; want false, nil", loggedIn, err)
+	}
+
+	// Requests without a valid session are redirected to the login page, rather than served
+	_, err = client.GetDirectory(ctx, 'A')
+	var statusErr directory.UnexpectedStatusError
+	if !errors.As(err, &statusErr) || statusErr.Code != 302 {
+		t.Fatalf("GetDirectory with an expired session = %v, want a 302 UnexpectedStatusError", err)
+	}
+}
+
+func TestReloginWhenSessionExpires(t *testing.T) {
+	ctx := context.Background()
+	people := fakeutsa.GeneratePeople(1, 30)
+	fake, client, memory := newFakeDirectoryWithStore(t, people)
+	client.Username, client.Password = fake.Username, fake.Password
+
+	if err := client.Login(ctx, fake.Username, fake.Password); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	fake.ExpireSessions()
+
+	// Every worker is turned away at once, but only one of them logs in again
+	var wg sync.WaitGroup
+	for _, letter := range []rune("ABCDEFGH") {
+		wg.Add(1)
+		go func(letter rune) {
+			defer wg.Done()
+			if _, err := client.GetDirectory(ctx, letter); err != nil {
+				t.Errorf("GetDirectory(%c) with an expired session: %v", letter, err)
+			}
+		}(letter)
+	}
+	for _, person := range people[:5] {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			if _, err := client.GetFullEntry(ctx, id); err != nil {
+				t.Errorf("GetFullEntry(%s) with an expired session: %v", id, err)
+			}
+		}(person.Entry.Id)
+	}
+	wg.Wait()
+
+	if fake.Logins() != 2 {
+		t.Errorf("fake recorded %d logins, want 2 (the first and a single re-login)", fake.Logins())
+	}
+
+	// The new session is saved, so the next run need not log in again
+	cookies, err := memory.LoadCookies()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.ContainsFunc(cookies, func(cookie http.Cookie) bool { return cookie.Name == ".ADAuthCookie" }) {
+		t.Errorf("saved cookies %v do not include the auth cookie", cookies)
+	}
+}
+
+func TestReloginFailureIsNotRetried(t *testing.T) {
+	ctx := context.Background()
+	fake, client := newFakeDirectory(t, fakeutsa.GeneratePeople(1, 5))
+
+	if err := client.Login(ctx, fake.Username, fake.Password); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	fake.ExpireSessions()
+	client.Username, client.Password = fake.Username, "changed"
+
+	for i := 0; i < 2; i++ {
+		_, err := client.GetDirectory(ctx, 'A')
+		var loginErr directory.LoginError
+		if !errors.As(err, &loginErr) {
+			t.Fatalf("GetDirectory after a failed re-login = %v, want a LoginError", err)
+		}
+	}
+
+	// Fixing the credentials does not help, as the failure is remembered for the rest of the run
+	client.Password = fake.Password
+	if _, err := client.GetDirectory(ctx, 'A'); err == nil {
+		t.Error("GetDirectory after a failed re-login succeeded, want the remembered LoginError")
+	}
+	if fake.Logins() != 1 {
+		t.Errorf("fake recorded %d logins, want only the first", fake.Logins())
+	}
+}
+
+func findPerson(t *testing.T, people []fakeutsa.Person, id string) fakeutsa.Person {
+	t.Helper()
+	for _, person := range people {
+		if person.Entry.Id == id {
+			return person
+		}
+	}
+	t.Fatalf("scraped entry %s is not one of the fake's people", id)
+	return fakeutsa.Person{}
+}
+
+// newCachedEntry logs into a fake directory and caches a doctored copy of a person's full entry, so that whether it was
+// served from the cache or fetched again can be told apart
+func newCachedEntry(t *testing.T, policy directory.CachePolicy) (*directory.Client, *store.MemoryStore, fakeutsa.Person) {
+	t.Helper()
+	people := fakeutsa.GeneratePeople(1, 10)
+	fake, client, memory := newFakeDirectoryWithStore(t, people)
+	client.CachePolicy = policy
+	if err := client.Login(context.Background(), fake.Username, fake.Password); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	person := people[0]
+	doctored := person.Full
+	doctored.Title = "Cached Title"
+	if err := memory.SetEntry(person.Entry.Id, &doctored); err != nil {
+		t.Fatal(err)
+	}
+	return client, memory, person
+}
+
+func TestCachedEntryFresh(t *testing.T) {
+	client, _, person := newCachedEntry(t, directory.CachePolicy{EntryTTL: time.Hour})
+
+	full, cached, err := client.GetFullEntryCached(context.Background(), person.Entry.Id)
+	if err != nil || !cached || full.Title != "Cached Title" {
+		t.Fatalf("GetFullEntryCached = %+v, %t, %v; want the cached entry", full, cached, err)
+	}
+}
+
+func TestCachedEntryExpired(t *testing.T) {
+	client, memory, person := newCachedEntry(t, directory.CachePolicy{EntryTTL: time.Nanosecond})
+
+	full, cached, err := client.GetFullEntryCached(context.Background(), person.Entry.Id)
+	if err != nil || cached || !reflect.DeepEqual(*full, person.Full) {
+		t.Fatalf("GetFullEntryCached = %+v, %t, %v; want %+v fetched again", full, cached, err, person.Full)
+	}
+	if saved, _, _, _ := memory.GetEntry(person.Entry.Id); !reflect.DeepEqual(*saved, person.Full) {
+		t.Errorf("cached entry = %+v, want it replaced by %+v", *saved, person.Full)
+	}
+}
+
+func TestCachedEntryStaleWhileRevalidate(t *testing.T) {
+	client, memory, person := newCachedEntry(t, directory.CachePolicy{EntryTTL: time.Nanosecond, StaleWhileRevalidate: true, RefreshTimeout: time.Minute})
+
+	// The stale entry is served at once, and is refreshed even though the request that served it is over
+	ctx, cancel := context.WithCancel(context.Background())
+	full, cached, err := client.GetFullEntryCached(ctx, person.Entry.Id)
+	cancel()
+	if err != nil || !cached || full.Title != "Cached Title" {
+		t.Fatalf("GetFullEntryCached = %+v, %t, %v; want the stale entry", full, cached, err)
+	}
+
+	if err := client.WaitRefreshes(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if saved, _, _, _ := memory.GetEntry(person.Entry.Id); !reflect.DeepEqual(*saved, person.Full) {
+		t.Errorf("cached entry after revalidating = %+v, want %+v", *saved, person.Full)
+	}
+}
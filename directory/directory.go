@@ -0,0 +1,197 @@
+package directory
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+
+	"unsubscribe/metrics"
+)
+
+// GetFullDirectory collects the (cached) directory entries for every letter A-Z
+func (c *Client) GetFullDirectory(ctx context.Context) ([]Entry, error) {
+	entries := make([]Entry, 0, 500)
+	for letter := 'A'; letter <= 'Z'; letter++ {
+		letterEntries, err := c.GetDirectoryCached(ctx, letter)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get directory")
+		}
+
+		entries = append(entries, letterEntries...)
+	}
+
+	return entries, nil
+}
+
+// GetDirectoryCached returns the directory entries for a letter, fetching and caching them if not cached or expired
+func (c *Client) GetDirectoryCached(ctx context.Context, letter rune) ([]Entry, error) {
+	// Check if cached
+	entries, cachedAt, cached, err := c.cache.GetDirectory(letter)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to load from cache")
+	} else if !cached {
+		log.Warn().Str("letter", string(letter)).Msg("Directory Cache Not Found")
+	}
+
+	// If cached and fresh, return it
+	if cached {
+		if !expired(cachedAt, c.CachePolicy.DirectoryTTL) {
+			metrics.CacheLookups.WithLabelValues("directory", "hit").Inc()
+			return entries, nil
+		}
+
+		if c.CachePolicy.StaleWhileRevalidate {
+			metrics.CacheLookups.WithLabelValues("directory", "stale").Inc()
+			c.revalidate(ctx, fmt.Sprintf("directory:%s", string(letter)), func(ctx context.Context) error {
+				_, err := c.refreshDirectory(ctx, letter)
+				return err
+			})
+			return entries, nil
+		}
+
+		metrics.CacheLookups.WithLabelValues("directory", "expired").Inc()
+		log.Info().Str("letter", string(letter)).Time("cachedAt", cachedAt).Msg("Directory Cache Expired")
+	}
+
+	// If not cached, get it
+	if !cached {
+		metrics.CacheLookups.WithLabelValues("directory", "miss").Inc()
+	}
+	return c.refreshDirectory(ctx, letter)
+}
+
+// refreshDirectory fetches the directory entries for a letter and saves them to the cache
+func (c *Client) refreshDirectory(ctx context.Context, letter rune) ([]Entry, error) {
+	entries, err := c.GetDirectory(ctx, letter)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get directory")
+	}
+
+	// Cache it
+	log.Debug().Str("letter", string(letter)).Msg("Saving to Directory Cache")
+	err = c.cache.SetDirectory(letter, entries)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to save to cache")
+	}
+
+	return entries, nil
+}
+
+// GetDirectory fetches and parses the directory page listing every person whose last name starts with the letter
+func (c *Client) GetDirectory(ctx context.Context, letter rune) ([]Entry, error) {
+	// Build the request
+	directoryPageUrl, _ := url.Parse(c.BaseUrl + "/directory/SearchByLastName")
+	query := directoryPageUrl.Query()
+	query.Set("abc", string(letter))
+	directoryPageUrl.RawQuery = query.Encode()
+
+	// Send the request, logging in again if the session has expired
+	response, err := c.getAuthenticated(ctx, directoryPageUrl.String())
+	if err != nil {
+		return nil, errors.Wrap(err, "error sending directory request")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != 200 {
+		return nil, UnexpectedStatusError{Url: directoryPageUrl.String(), Code: response.StatusCode}
+	}
+
+	// Parse the response
+	entries, err := ParseDirectory(response.Body)
+	if err != nil {
+		return nil, withUrl(err, directoryPageUrl.String())
+	}
+
+	return entries, nil
+}
+
+// GetFullEntryCached returns the full entry for an ID, fetching and caching it if not cached or expired.
+// The boolean is true if the entry came from the cache.
+func (c *Client) GetFullEntryCached(ctx context.Context, id string) (*FullEntry, bool, error) {
+	// Check if cached
+	entry, cachedAt, cached, err := c.cache.GetEntry(id)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to load from cache")
+	} else if !cached {
+		log.Debug().Str("id", id).Msg("Entry Cache Not Found")
+	}
+
+	// If cached and fresh, return it
+	if cached {
+		if !expired(cachedAt, c.CachePolicy.EntryTTL) {
+			metrics.CacheLookups.WithLabelValues("entry", "hit").Inc()
+			return entry, true, nil
+		}
+
+		if c.CachePolicy.StaleWhileRevalidate {
+			metrics.CacheLookups.WithLabelValues("entry", "stale").Inc()
+			c.revalidate(ctx, fmt.Sprintf("entry:%s", id), func(ctx context.Context) error {
+				_, err := c.refreshFullEntry(ctx, id)
+				return err
+			})
+			return entry, true, nil
+		}
+
+		metrics.CacheLookups.WithLabelValues("entry", "expired").Inc()
+		log.Debug().Str("id", id).Time("cachedAt", cachedAt).Msg("Entry Cache Expired")
+	}
+
+	// If not cached, get it
+	if !cached {
+		metrics.CacheLookups.WithLabelValues("entry", "miss").Inc()
+	}
+	entry, err = c.refreshFullEntry(ctx, id)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return entry, false, nil
+}
+
+// refreshFullEntry fetches the full entry for an ID and saves it to the cache
+func (c *Client) refreshFullEntry(ctx context.Context, id string) (*FullEntry, error) {
+	entry, err := c.GetFullEntry(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Cache it
+	log.Debug().Str("id", id).Msg("Saving to Entry Cache")
+	err = c.cache.SetEntry(id, entry)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to save to cache")
+	}
+
+	return entry, nil
+}
+
+// GetFullEntry fetches and parses the detail page of a single person
+func (c *Client) GetFullEntry(ctx context.Context, id string) (*FullEntry, error) {
+	// Build the request
+	directoryPageUrl, _ := url.Parse(c.BaseUrl + "/directory/Person_Detail")
+	query := directoryPageUrl.Query()
+	query.Set("abc", id)
+	directoryPageUrl.RawQuery = query.Encode()
+
+	// Send the request, logging in again if the session has expired
+	response, err := c.getAuthenticated(ctx, directoryPageUrl.String())
+	if err != nil {
+		return nil, errors.Wrap(err, "error sending entry request")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != 200 {
+		return nil, UnexpectedStatusError{Url: directoryPageUrl.String(), Code: response.StatusCode}
+	}
+
+	// Parse the response
+	entry, err := ParseFullEntry(response.Body)
+	if err != nil {
+		return nil, withUrl(err, directoryPageUrl.String())
+	}
+
+	return entry, nil
+}
@@ -0,0 +1,54 @@
+package directory
+
+import "fmt"
+
+// LoginError is returned when a login attempt fails, naming the step of the login flow that failed
+type LoginError struct {
+	Step   string
+	Reason string
+	Err    error // The underlying error, if any
+}
+
+// UnexpectedStatusError is returned when a response has a status code the caller does not know how to handle
+type UnexpectedStatusError struct {
+	Url  string
+	Code int
+}
+
+// ParseError is returned when a page could not be parsed, or does not have the expected structure
+type ParseError struct {
+	Url    string
+	Reason string
+	Err    error // The underlying error, if any
+}
+
+func (e LoginError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("login failed (%s): %s: %v", e.Step, e.Reason, e.Err)
+	}
+	return fmt.Sprintf("login failed (%s): %s", e.Step, e.Reason)
+}
+
+func (e LoginError) Unwrap() error {
+	return e.Err
+}
+
+func (e UnexpectedStatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.Code, e.Url)
+}
+
+func (e ParseError) Error() string {
+	message := fmt.Sprintf("parse error: %s", e.Reason)
+	if e.Err != nil {
+		message = fmt.Sprintf("%s: %v", message, e.Err)
+	}
+	// The parsers themselves do not know the URL of the page
+	if e.Url != "" {
+		message = fmt.Sprintf("%s (%s)", message, e.Url)
+	}
+	return message
+}
+
+func (e ParseError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,207 @@
+package directory
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/samber/lo"
+)
+
+// Login signs into the UTSA directory, leaving the auth cookie in the client's cookie jar
+func (c *Client) Login(ctx context.Context, username string, password string) error {
+	// Setup initial redirected request
+	directoryPageUrl, _ := url.Parse(c.BaseUrl + "/directory/Directory?action=Index")
+	request, _ := http.NewRequestWithContext(ctx, "GET", directoryPageUrl.String(), nil)
+	ApplyUtsaHeaders(request)
+	response, err := c.web.DoRequestNoRead(request)
+	if err != nil {
+		return LoginError{Step: "initial", Reason: "error sending initial request", Err: err}
+	}
+	response.Body.Close()
+
+	// Verify that we were redirected to the login page
+	if response.StatusCode != 302 {
+		return LoginError{Step: "initial", Reason: "no initial redirect", Err: UnexpectedStatusError{Url: request.URL.String(), Code: response.StatusCode}}
+	} else {
+		log.Debug().Str("location", response.Header.Get("Location")).Msg("Initial Page Redirected")
+	}
+
+	// Setup URL for request
+	loginPageUrl, _ := url.Parse(c.BaseUrl + "/directory/Account/Login")
+	query := loginPageUrl.Query()
+	query.Set("ReturnUrl", "/directory/AdvancedSearch")
+	loginPageUrl.RawQuery = query.Encode()
+
+	// Build request
+	request, _ = http.NewRequestWithContext(ctx, "GET", loginPageUrl.String(), nil)
+	ApplyUtsaHeaders(request)
+
+	// Send request
+	response, err = c.web.DoRequestNoRead(request)
+	if err != nil {
+		return LoginError{Step: "login page", Reason: "error sending login page request", Err: err}
+	}
+	doc, err := goquery.NewDocumentFromReader(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return LoginError{Step: "login page", Reason: "error parsing response body", Err: ParseError{Url: request.URL.String(), Reason: "invalid html", Err: err}}
+	}
+
+	// Get token
+	token, _ := doc.Find("input[name='__RequestVerificationToken']").Attr("value")
+	log.Debug().Str("token", token).Msg("Token Captured")
+
+	// Build the login request
+	form := url.Values{
+		"__RequestVerificationToken": {token},
+		"myUTSAID":                   {username},
+		"passphrase":                 {password},
+		"log-me-in":                  {"Log+In"},
+	}
+	request, _ = http.NewRequestWithContext(ctx, "POST", c.BaseUrl+"/directory/", strings.NewReader(form.Encode()))
+	ApplyUtsaHeaders(request)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	// Send the login request
+	response, err = c.web.DoRequestNoRead(request)
+
+	if err != nil {
+		return LoginError{Step: "submit", Reason: "error sending login request", Err: err}
+	}
+	response.Body.Close()
+
+	if response.StatusCode != 200 {
+		switch response.StatusCode {
+		case 302: // ignore
+
+		case 500:
+			return LoginError{Step: "submit", Reason: "bad request (check cookies)", Err: UnexpectedStatusError{Url: request.URL.String(), Code: response.StatusCode}}
+		default:
+			return LoginError{Step: "submit", Reason: "unknown error", Err: UnexpectedStatusError{Url: request.URL.String(), Code: response.StatusCode}}
+		}
+	}
+
+	// Check for Set-Cookie of ".ADAuthCookie"
+	newCookies := response.Header.Values("Set-Cookie")
+	authCookie, found := lo.Find(newCookies, func(cookie string) bool {
+		return strings.Contains(cookie, ".ADAuthCookie")
+	})
+
+	if !found {
+		return LoginError{Step: "submit", Reason: "could not find auth cookie"}
+	} else {
+		log.Info().Str("authCookie", authCookie).Msg("Auth Cookie Found")
+	}
+
+	// Check if redirected to directory page
+	if response.Header.Get("Location") != "" {
+		log.Debug().Str("location", response.Header.Get("Location")).Msg("Redirected")
+	} else {
+		return LoginError{Step: "submit", Reason: "no redirect"}
+	}
+
+	// Request the redirect page
+	redirectUrl := fmt.Sprintf("%s%s", c.BaseUrl, response.Header.Get("Location"))
+	if location, err := response.Location(); err == nil {
+		redirectUrl = location.String()
+	}
+	request, _ = http.NewRequestWithContext(ctx, "GET", redirectUrl, nil)
+	ApplyUtsaHeaders(request)
+	response, err = c.web.DoRequestNoRead(request)
+	if err != nil {
+		return LoginError{Step: "redirect", Reason: "error sending redirect request", Err: err}
+	} else if response.StatusCode != 200 {
+		response.Body.Close()
+		return LoginError{Step: "redirect", Reason: "non-200 status after login attempt", Err: UnexpectedStatusError{Url: redirectUrl, Code: response.StatusCode}}
+	}
+
+	// Parse the response body
+	doc, err = goquery.NewDocumentFromReader(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return LoginError{Step: "redirect", Reason: "error parsing response body", Err: ParseError{Url: redirectUrl, Reason: "invalid html", Err: err}}
+	}
+
+	// Look for field validation errors (untested)
+	validationErrors := doc.Find("span.field-validation-error")
+	if validationErrors.Length() > 0 {
+		event := log.Debug().Int("validationErrors", validationErrors.Length())
+		validationErrors.Each(func(i int, s *goquery.Selection) {
+			event.Str(fmt.Sprintf("err_%d", i+1), s.Text())
+		})
+		return LoginError{Step: "redirect", Reason: fmt.Sprintf("validation error: %s", validationErrors.First().Text())}
+	}
+
+	// Look for the 'Log Off' link
+	logOffFound := false
+	doc.Find("a.dropdown-item").Each(func(i int, s *goquery.Selection) {
+		if !logOffFound && strings.Contains(s.Text(), "Log Off") {
+			log.Debug().Int("index", i).Msg("Log Off Element Found")
+			logOffFound = true
+		}
+	})
+
+	if !logOffFound {
+		return LoginError{Step: "redirect", Reason: "could not find log off element"}
+	}
+
+	return nil
+}
+
+// CheckLoggedIn checks whether the cookie jar holds a still-valid auth cookie
+func (c *Client) CheckLoggedIn(ctx context.Context) (bool, error) {
+	// Check if required cookie exists
+	utsaUrl, _ := url.Parse(c.BaseUrl)
+	cookies := c.web.HTTP.Jar.Cookies(utsaUrl)
+	_, authCookieFound := lo.Find(cookies, func(cookie *http.Cookie) bool {
+		return cookie.Name == ".ADAuthCookie"
+	})
+
+	if !authCookieFound {
+		log.Debug().Int("count", len(cookies)).Msg("ActiveDirectory Auth Cookie Not Found")
+		return false, nil
+	}
+
+	// Send a authenticated-only request
+	directoryPageUrl, _ := url.Parse(c.BaseUrl + "/directory/AdvancedSearch")
+	request, _ := http.NewRequestWithContext(ctx, "GET", directoryPageUrl.String(), nil)
+	ApplyUtsaHeaders(request)
+	response, err := c.web.DoRequestNoRead(request)
+	if err != nil {
+		return false, errors.Wrap(err, "could not send redirect check request")
+	}
+
+	defer response.Body.Close()
+
+	// If it's not a 302
+	if response.StatusCode != 302 {
+		// Anything other than the page itself or a redirect to login is left to the caller
+		if response.StatusCode != 200 {
+			return false, UnexpectedStatusError{Url: request.URL.String(), Code: response.StatusCode}
+		}
+
+		// Parse the response document
+		doc, err := goquery.NewDocumentFromReader(response.Body)
+		if err != nil {
+			return false, ParseError{Url: request.URL.String(), Reason: "invalid html", Err: err}
+		}
+
+		// Try to find the log out button
+		logOffFound := false
+		doc.Find("a.dropdown-item").Each(func(i int, s *goquery.Selection) {
+			if !logOffFound && strings.Contains(s.Text(), "Log Off") {
+				log.Debug().Int("index", i).Msg("Log Off Element Found")
+				logOffFound = true
+			}
+		})
+		return true, nil
+	}
+
+	return false, nil
+}
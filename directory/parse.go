@@ -0,0 +1,163 @@
+package directory
+
+import (
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/samber/lo"
+)
+
+// ParseDirectory parses a directory page, as returned by SearchByLastName, into its entries.
+// A page without any rows, or whose rows yield no entries, is a ParseError, as it most likely means the markup changed.
+func ParseDirectory(r io.Reader) ([]Entry, error) {
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return nil, ParseError{Reason: "invalid html", Err: err}
+	}
+
+	// Acquire selector
+	rows := doc.Find("table#peopleTable > tbody > tr")
+	entries := make([]Entry, 0, rows.Length())
+	log.Debug().Int("count", rows.Length()).Msg("Rows Found")
+
+	// Check number of rows
+	if rows.Length() < 1 {
+		return nil, ParseError{Reason: "no rows found in directory"}
+	} else if rows.Length() <= 20 {
+		log.Warn().Int("count", rows.Length()).Msg("Low number of rows found")
+	}
+
+	// Iterate over rows
+	rows.Each(func(i int, s *goquery.Selection) {
+		entry := Entry{}
+		nameElement := s.Find("a.fullName")
+
+		// Process the HREF URL into an actual ID
+		personPath, exists := nameElement.Attr("href")
+		valueIndex := strings.Index(personPath, "abc=")
+		if !exists || valueIndex == -1 {
+			log.Warn().Str("href", personPath).Msg("Could not find ID in HREF")
+			return
+		}
+		unescapedId, err := url.QueryUnescape(personPath[valueIndex+4:])
+		if err != nil {
+			log.Warn().Str("href", personPath).Msg("Could not unescape ID")
+			return
+		}
+		entry.Id = unescapedId
+
+		entry.Name = strings.TrimSpace(nameElement.Text())
+
+		entry.JobTitle = strings.TrimSpace(s.Find("span.jobtitle").Text())
+		entry.Department = strings.TrimSpace(s.Find("span.dept").Text())
+		entry.College = strings.TrimSpace(s.Find("span.college").Text())
+		entry.Phone = strings.TrimSpace(s.Find("span.phone").Text())
+
+		entries = append(entries, entry)
+	})
+
+	if len(entries) == 0 {
+		return nil, ParseError{Reason: "no entries found in directory rows"}
+	}
+
+	return entries, nil
+}
+
+// ParseFullEntry parses the detail page of a single person, as returned by Person_Detail.
+// A page without any detail rows or a name is a ParseError, as it most likely means the markup changed.
+func ParseFullEntry(r io.Reader) (*FullEntry, error) {
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return nil, ParseError{Reason: "invalid html", Err: err}
+	}
+
+	// Move all rows into a map
+	rows := make(map[string]string)
+	rowElements := doc.Find("table.detail > tbody > tr")
+	log.Debug().Int("count", rowElements.Length()).Msg("Rows Found")
+
+	// Check number of rows
+	if rowElements.Length() < 1 {
+		return nil, ParseError{Reason: "no rows found in entry"}
+	}
+
+	// Iterate over rows
+	rowElements.Each(func(i int, s *goquery.Selection) {
+		// left hand column
+		rowTitle := NormalizeTitle(strings.TrimRight(
+			strings.TrimSpace(s.Find("th > strong").Text()), ":",
+		))
+
+		// right hand column, add to map
+		rows[rowTitle] = strings.TrimSpace(s.Find("td").Text())
+	})
+
+	// Build the entry from the map
+	entry := FullEntry{}
+
+	entry.Classification = rows["classification"]
+	delete(rows, "classification")
+
+	entry.College = rows["college"]
+	delete(rows, "college")
+
+	entry.Major = rows["major"]
+	delete(rows, "major")
+
+	entry.Email = rows["email"]
+	delete(rows, "email")
+
+	entry.Title = rows["title"]
+	delete(rows, "title")
+
+	entry.Department = rows["department"]
+	delete(rows, "department")
+
+	entry.MailingAddress = rows["mailing-address"]
+	delete(rows, "mailing-address")
+
+	entry.Building = rows["building"]
+	delete(rows, "building")
+
+	entry.Phone = rows["phone"]
+	delete(rows, "phone")
+
+	entry.Other = rows
+
+	// Multiple names found, collect and log
+	nameElement := doc.Find("body > #main span.nameBold > strong")
+	if nameElement.Length() > 1 {
+		var names []string
+		nameElement.Each(func(i int, s *goquery.Selection) {
+			names = append(names, strings.TrimSpace(s.Text()))
+		})
+		log.Warn().Int("count", nameElement.Length()).Interface("names", names).Msg("Multiple Names Found")
+
+		// Use the longest name
+		entry.Name = lo.MaxBy(names, func(name string, max string) bool {
+			return len(name) > len(max)
+		})
+	} else {
+		entry.Name = strings.TrimSpace(nameElement.Text())
+	}
+
+	if entry.Name == "" {
+		return nil, ParseError{Reason: "no name found in entry"}
+	}
+
+	return &entry, nil
+}
+
+// withUrl fills in the URL of a ParseError returned by a parser, which has no way of knowing it
+func withUrl(err error, url string) error {
+	var parseErr ParseError
+	if errors.As(err, &parseErr) {
+		parseErr.Url = url
+		return parseErr
+	}
+	return err
+}
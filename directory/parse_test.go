@@ -0,0 +1,113 @@
+package directory
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files with the current parser output")
+
+// goldenResult is what a golden file holds: either the parsed value, or the error the parser returned
+type goldenResult struct {
+	Value any    `json:"value,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// TestParseGolden parses each testdata/*.html fixture with the parser its name prefix selects,
+// comparing the result against the matching .golden.json file.
+func TestParseGolden(t *testing.T) {
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "*.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatal("no fixtures found in testdata")
+	}
+
+	for _, fixture := range fixtures {
+		name := strings.TrimSuffix(filepath.Base(fixture), ".html")
+		t.Run(name, func(t *testing.T) {
+			file, err := os.Open(fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+
+			var result goldenResult
+			switch {
+			case strings.HasPrefix(name, "directory"):
+				entries, err := ParseDirectory(file)
+				result = goldenResult{Value: entries}
+				if err != nil {
+					result = goldenResult{Error: err.Error()}
+				}
+			case strings.HasPrefix(name, "entry"):
+				entry, err := ParseFullEntry(file)
+				result = goldenResult{Value: entry}
+				if err != nil {
+					result = goldenResult{Error: err.Error()}
+				}
+			default:
+				t.Fatalf("fixture %s has no parser, its name must start with directory or entry", fixture)
+			}
+
+			got, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+
+			goldenPath := filepath.Join("testdata", name+".golden.json")
+			if *update {
+				if err := os.WriteFile(goldenPath, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			want, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatalf("missing golden file, run with -update to create it: %v", err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("parsed %s does not match %s\ngot:\n%s\nwant:\n%s", fixture, goldenPath, got, want)
+			}
+		})
+	}
+}
+
+func TestParseErrorsAreTyped(t *testing.T) {
+	_, err := ParseDirectory(strings.NewReader("<html><body></body></html>"))
+	if _, ok := err.(ParseError); !ok {
+		t.Errorf("ParseDirectory returned %T, want ParseError", err)
+	}
+
+	_, err = ParseFullEntry(strings.NewReader("<html><body></body></html>"))
+	if _, ok := err.(ParseError); !ok {
+		t.Errorf("ParseFullEntry returned %T, want ParseError", err)
+	}
+
+	err = withUrl(ParseError{Reason: "no rows found in entry"}, "https://www.utsa.edu/directory/Person_Detail?abc=x")
+	if parseErr, ok := err.(ParseError); !ok || parseErr.Url != "https://www.utsa.edu/directory/Person_Detail?abc=x" {
+		t.Errorf("withUrl returned %#v, want a ParseError with the URL filled in", err)
+	}
+}
+
+func TestNormalizeTitle(t *testing.T) {
+	for title, want := range map[string]string{
+		"Mailing Address":         "mailing-address",
+		"Mailing   | Address":     "mailing-address",
+		"  Mailing Address  ":     "mailing-address",
+		"  Mailing   | Address  ": "mailing-address",
+		"E-mail":                  "e-mail",
+		"Classification":          "classification",
+	} {
+		if got := NormalizeTitle(title); got != want {
+			t.Errorf("NormalizeTitle(%q) = %q, want %q", title, got, want)
+		}
+	}
+}
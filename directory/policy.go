@@ -0,0 +1,73 @@
+package directory
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// CachePolicy controls how long cached directory pages and entries are considered fresh
+type CachePolicy struct {
+	DirectoryTTL time.Duration // Zero means directory pages never expire
+	EntryTTL     time.Duration // Zero means entries never expire
+
+	// Serve expired values immediately, refreshing them in the background
+	StaleWhileRevalidate bool
+	// RefreshTimeout bounds each background refresh, which outlives the request that started it
+	RefreshTimeout time.Duration
+}
+
+// DefaultCachePolicy refreshes directory pages weekly, so new students are found, and entries rarely
+var DefaultCachePolicy = CachePolicy{
+	DirectoryTTL:   7 * 24 * time.Hour,
+	EntryTTL:       90 * 24 * time.Hour,
+	RefreshTimeout: 2 * time.Minute,
+}
+
+// expired returns true if a value cached at cachedAt is older than ttl
+func expired(cachedAt time.Time, ttl time.Duration) bool {
+	return ttl > 0 && time.Since(cachedAt) > ttl
+}
+
+// revalidate runs refresh in the background, unless a refresh for the same key is already running.
+// The refresh is not canceled along with the request that served the stale value, only by the policy's RefreshTimeout.
+func (c *Client) revalidate(ctx context.Context, key string, refresh func(ctx context.Context) error) {
+	if _, running := c.refreshing.LoadOrStore(key, struct{}{}); running {
+		return
+	}
+
+	c.refreshes.Add(1)
+	go func() {
+		defer c.refreshes.Done()
+		defer c.refreshing.Delete(key)
+
+		refreshCtx, cancel := context.WithoutCancel(ctx), context.CancelFunc(func() {})
+		if c.CachePolicy.RefreshTimeout > 0 {
+			refreshCtx, cancel = context.WithTimeout(refreshCtx, c.CachePolicy.RefreshTimeout)
+		}
+		defer cancel()
+
+		log.Debug().Str("key", key).Msg("Revalidating Stale Cache")
+		if err := refresh(refreshCtx); err != nil {
+			log.Err(err).Str("key", key).Msg("Failed to revalidate stale cache")
+		}
+	}()
+}
+
+// WaitRefreshes blocks until every background refresh started by stale-while-revalidate has finished,
+// or returns the context's error if it is done first, leaving the remaining refreshes running
+func (c *Client) WaitRefreshes(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		c.refreshes.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,80 @@
+package directory
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// isLoginRedirect returns true if the response redirects to the login page, as every page does once the session has expired
+func isLoginRedirect(response *http.Response) bool {
+	if response.StatusCode < 300 || response.StatusCode >= 400 {
+		return false
+	}
+
+	location, err := response.Location()
+	return err == nil && strings.HasPrefix(strings.ToLower(location.Path), "/directory/account/login")
+}
+
+// getAuthenticated sends a GET request for a page that requires a session. If the session has expired, the client logs in
+// again with its credentials and retries the request once. Without credentials, or if the session is still not accepted
+// afterwards, the login redirect is returned for the caller to handle like any other unexpected status.
+func (c *Client) getAuthenticated(ctx context.Context, pageUrl string) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		// The generation is read before sending, so a login finished while this request was in flight is not repeated
+		generation := c.sessionGeneration()
+
+		request, _ := http.NewRequestWithContext(ctx, "GET", pageUrl, nil)
+		ApplyUtsaHeaders(request)
+		response, err := c.web.DoRequestNoRead(request)
+		if err != nil {
+			return nil, err
+		}
+
+		if attempt > 1 || c.Username == "" || !isLoginRedirect(response) {
+			return response, nil
+		}
+		response.Body.Close()
+
+		log.Warn().Str("url", pageUrl).Msg("Session Expired")
+		if err := c.relogin(ctx, generation); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// sessionGeneration returns how many times the session has been renewed by relogin
+func (c *Client) sessionGeneration() int {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	return c.generation
+}
+
+// relogin logs in again and saves the new cookies, unless the session was already renewed since the given generation.
+// Every worker whose request was turned away waits here, so only the first of them logs in. A failed login is not retried,
+// and is returned to every later caller.
+func (c *Client) relogin(ctx context.Context, generation int) error {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+
+	if c.reloginErr != nil {
+		return c.reloginErr
+	} else if c.generation != generation {
+		return nil
+	}
+
+	log.Info().Str("username", c.Username).Msg("Logging In Again")
+	if err := c.Login(ctx, c.Username, c.Password); err != nil {
+		// A canceled login says nothing about the credentials, so it may be tried again
+		if ctx.Err() == nil {
+			c.reloginErr = err
+		}
+		return err
+	}
+
+	c.generation++
+	c.SaveCookies()
+	return nil
+}
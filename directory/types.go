@@ -1,4 +1,4 @@
-package main
+package directory
 
 type Entry struct {
 	Id         string
@@ -23,16 +23,3 @@ type FullEntry struct {
 	Phone          string
 	Other          map[string]string
 }
-
-type ConfirmationResponse struct {
-	FormId              string `json:"formId"`
-	FollowUpUrl         string `json:"followUpUrl"`
-	DeliveryType        string `json:"deliveryType"`
-	FollowUpStreamValue string `json:"followUpStreamValue"`
-	AliId               string `json:"aliId"`
-}
-
-type ErrorResponse struct {
-	Message string `json:"message"`
-	Code    int    `json:"code"`
-}
@@ -0,0 +1,221 @@
+// Package fakemarketo emulates the Marketo lead capture endpoint the SCLA's unsubscribe form is submitted to.
+// It validates each submission's checksum like the real endpoint, responds as configured, and records every submission.
+package fakemarketo
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Behavior decides how the server responds to submissions
+type Behavior string
+
+const (
+	Succeed       Behavior = "succeed"        // Confirm the unsubscribe, if the checksum is valid
+	Reject        Behavior = "reject"         // Respond with a "Rejected" JSON error, if the checksum is valid
+	RateLimit     Behavior = "rate-limit"     // Respond 429 with an HTML page, before validating anything
+	HTMLError     Behavior = "html-error"     // Respond with an HTML error page, before validating anything
+	UnknownError  Behavior = "unknown-error"  // Respond with a JSON error the client does not know, if the checksum is valid
+	MalformedJSON Behavior = "malformed-json" // Respond with an error claiming to be JSON that is not, if the checksum is valid
+)
+
+// Behaviors lists every Behavior, in the order they are documented
+var Behaviors = []Behavior{Succeed, Reject, RateLimit, HTMLError, UnknownError, MalformedJSON}
+
+// Result is the outcome of a submission, as reported by the server
+type Result string
+
+const (
+	ResultSucceeded       Result = "succeeded"
+	ResultChecksumMissing Result = "checksum missing"
+	ResultChecksumInvalid Result = "checksum invalid"
+	ResultRejected        Result = "rejected"
+	ResultRateLimited     Result = "rate limited"
+	ResultError           Result = "error"
+)
+
+// Submission is a single form received by the server, along with how it was answered
+type Submission struct {
+	Email  string
+	Values url.Values
+	Result Result
+	Code   int
+	Time   time.Time
+}
+
+// Server serves a fake lead capture endpoint at /index.php/leadCapture/save2
+type Server struct {
+	// MunchkinId, if not empty, causes submissions for any other munchkin ID to be rejected
+	MunchkinId string
+	// HTMLStatus is the status code of HTMLError responses, defaulting to 500
+	HTMLStatus int
+	// RetryAfter is sent with RateLimit responses
+	RetryAfter time.Duration
+
+	mu          sync.Mutex
+	behavior    Behavior
+	queued      []Behavior
+	submissions []Submission
+}
+
+// New creates a Server that responds to every submission with the given behavior
+func New(behavior Behavior) *Server {
+	return &Server{behavior: behavior, HTMLStatus: http.StatusInternalServerError}
+}
+
+// SetBehavior changes how the server responds to submissions, once any queued behaviors are used up
+func (s *Server) SetBehavior(behavior Behavior) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.behavior = behavior
+}
+
+// Queue makes the next submissions be answered with the given behaviors in order, before falling back to the set behavior
+func (s *Server) Queue(behaviors ...Behavior) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queued = append(s.queued, behaviors...)
+}
+
+// Submissions returns a copy of every submission received so far
+func (s *Server) Submissions() []Submission {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Submission(nil), s.submissions...)
+}
+
+// nextBehavior pops the next queued behavior, or returns the set behavior
+func (s *Server) nextBehavior() Behavior {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queued) > 0 {
+		behavior := s.queued[0]
+		s.queued = s.queued[1:]
+		return behavior
+	}
+	return s.behavior
+}
+
+// record adds a submission to the record
+func (s *Server) record(values url.Values, result Result, code int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.submissions = append(s.submissions, Submission{
+		Email:  values.Get("Email"),
+		Values: values,
+		Result: result,
+		Code:   code,
+		Time:   time.Now(),
+	})
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/index.php/leadCapture/save2" && r.Method == http.MethodPost:
+		s.serveSave(w, r)
+	case r.URL.Path == "/UnsubscribePage.html" && r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, "<!DOCTYPE html><html><head><title>Unsubscribe</title></head><body><form id=\"mktoForm_1\"></form></body></html>")
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) serveSave(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	values := r.PostForm
+	behavior := s.nextBehavior()
+
+	// These happen in front of the endpoint, so they answer regardless of what was submitted
+	switch behavior {
+	case RateLimit:
+		w.Header().Set("Retry-After", strconv.Itoa(int(s.RetryAfter.Seconds())))
+		writeHTML(w, http.StatusTooManyRequests, "429 Too Many Requests")
+		s.record(values, ResultRateLimited, http.StatusTooManyRequests)
+		return
+	case HTMLError:
+		writeHTML(w, s.HTMLStatus, fmt.Sprintf("%d %s", s.HTMLStatus, http.StatusText(s.HTMLStatus)))
+		s.record(values, ResultError, s.HTMLStatus)
+		return
+	}
+
+	if result := validateChecksum(values); result != "" {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"message": string(result), "code": http.StatusBadRequest})
+		s.record(values, result, http.StatusBadRequest)
+		return
+	}
+
+	if behavior == Reject || (s.MunchkinId != "" && values.Get("munchkinId") != s.MunchkinId) {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"message": "Rejected", "code": http.StatusBadRequest})
+		s.record(values, ResultRejected, http.StatusBadRequest)
+		return
+	}
+
+	switch behavior {
+	case UnknownError:
+		writeJSON(w, http.StatusBadRequest, map[string]any{"message": "Form not found", "code": http.StatusBadRequest})
+		s.record(values, ResultError, http.StatusBadRequest)
+	case MalformedJSON:
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"message": "checksum inv`)
+		s.record(values, ResultError, http.StatusBadRequest)
+	default:
+		s.mu.Lock()
+		aliId := len(s.submissions) + 1
+		s.mu.Unlock()
+
+		writeJSON(w, http.StatusOK, map[string]string{
+			"formId":              values.Get("formid"),
+			"followUpUrl":         values.Get("lpurl"),
+			"deliveryType":        "",
+			"followUpStreamValue": "",
+			"aliId":               strconv.Itoa(aliId),
+		})
+		s.record(values, ResultSucceeded, http.StatusOK)
+	}
+}
+
+// validateChecksum checks the checksum the way the real endpoint does: the SHA-256 of the value of each field named in
+// checksumFields, in that order, joined by '|'. It returns the failing Result, or an empty Result if the checksum is valid.
+func validateChecksum(values url.Values) Result {
+	checksum := values.Get("checksum")
+	checksumFields := values.Get("checksumFields")
+	if checksum == "" || checksumFields == "" {
+		return ResultChecksumMissing
+	}
+
+	fields := strings.Split(checksumFields, ",")
+	fieldValues := make([]string, len(fields))
+	for i, field := range fields {
+		fieldValues[i] = values.Get(field)
+	}
+
+	expected := fmt.Sprintf("%x", sha256.Sum256([]byte(strings.Join(fieldValues, "|"))))
+	if checksum != expected {
+		return ResultChecksumInvalid
+	}
+	return ""
+}
+
+func writeJSON(w http.ResponseWriter, code int, body any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeHTML(w http.ResponseWriter, code int, title string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(code)
+	fmt.Fprintf(w, "<!DOCTYPE html><html><head><title>%s</title></head><body><h1>%s</h1></body></html>", title, title)
+}
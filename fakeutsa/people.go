@@ -0,0 +1,105 @@
+package fakeutsa
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"unsubscribe/directory"
+)
+
+// Person is a synthetic member of the directory, as listed on a directory page and as shown on their detail page
+type Person struct {
+	Entry directory.Entry
+	Full  directory.FullEntry
+}
+
+// One last name per letter, so every directory page has someone on it
+var lastNames = []string{
+	"Abbott", "Barrera", "Castillo", "Dominguez", "Espinoza", "Flores", "Garza", "Herrera", "Ibarra",
+	"Jimenez", "Kennedy", "Lozano", "Morales", "Navarro", "Ortiz", "Perez", "Quintero", "Ramirez",
+	"Salinas", "Trevino", "Underwood", "Vasquez", "Whitaker", "Xiong", "Yates", "Zamora",
+}
+
+var firstNames = []string{
+	"Alex", "Casey", "Dana", "Emerson", "Finley", "Harper", "Jamie", "Jordan", "Kendall", "Logan",
+	"Morgan", "Parker", "Quinn", "Reese", "Riley", "Rowan", "Sage", "Skyler", "Taylor", "Sam",
+}
+
+var colleges = []string{
+	"College of Sciences", "College of Engineering and Integrated Design", "Carlos Alvarez College of Business",
+	"College of Liberal and Fine Arts", "College for Health, Community and Policy",
+}
+
+var majors = []string{"Computer Science", "Biology", "Mechanical Engineering", "Accounting", "Psychology", "Public Health"}
+
+var classifications = []string{"Freshman", "Sophomore", "Junior", "Senior", "Graduate"}
+
+var titles = []string{"Lecturer", "Assistant Professor", "Associate Professor", "Professor", "Administrative Associate"}
+
+var departments = []string{"Department of Computer Science", "Department of Biology", "Department of Mechanical Engineering", "Department of Accounting", "Office of the Registrar"}
+
+// GeneratePeople deterministically generates count synthetic people from the seed, roughly one in five being faculty.
+// The people are sorted by last name, then first name.
+func GeneratePeople(seed int64, count int) []Person {
+	random := rand.New(rand.NewSource(seed))
+	pick := func(values []string) string {
+		return values[random.Intn(len(values))]
+	}
+
+	people := make([]Person, 0, count)
+	seen := make(map[string]bool, count)
+	for len(people) < count {
+		// Spread people evenly over the letters, so that every page is populated
+		last := lastNames[len(people)%len(lastNames)]
+		first := pick(firstNames)
+		if seen[first+last] {
+			// Names are not unique in a real directory, but keeping them unique makes the pages easy to check
+			first = fmt.Sprintf("%s %c.", first, 'A'+rune(random.Intn(26)))
+			if seen[first+last] {
+				continue
+			}
+		}
+		seen[first+last] = true
+
+		// Padded base64 IDs, so that '+', '/' and '=' all need escaping in the detail URL
+		idBytes := make([]byte, 10)
+		random.Read(idBytes)
+		id := base64.StdEncoding.EncodeToString(idBytes)
+
+		person := Person{
+			Entry: directory.Entry{Id: id, Name: fmt.Sprintf("%s, %s", last, first), College: pick(colleges)},
+			Full:  directory.FullEntry{Name: fmt.Sprintf("%s %s", first, last), Other: map[string]string{}},
+		}
+		person.Full.College = person.Entry.College
+
+		emailName := strings.ToLower(strings.NewReplacer(" ", ".", ".", "").Replace(first) + "." + last)
+		if random.Intn(5) == 0 {
+			person.Entry.JobTitle = pick(titles)
+			person.Entry.Department = pick(departments)
+			person.Entry.Phone = fmt.Sprintf("(210) 555-%04d", random.Intn(10000))
+
+			person.Full.Title = person.Entry.JobTitle
+			person.Full.Department = person.Entry.Department
+			person.Full.Phone = person.Entry.Phone
+			person.Full.Email = emailName + "@utsa.edu"
+			person.Full.MailingAddress = "One UTSA Circle San Antonio, TX 78249"
+			person.Full.Building = fmt.Sprintf("Main Building %d.%02d", random.Intn(4)+1, random.Intn(100))
+		} else {
+			person.Entry.JobTitle = "Student"
+
+			person.Full.Classification = pick(classifications)
+			person.Full.Major = pick(majors)
+			person.Full.Email = emailName + "@my.utsa.edu"
+		}
+
+		people = append(people, person)
+	}
+
+	sort.Slice(people, func(i, j int) bool {
+		return people[i].Entry.Name < people[j].Entry.Name
+	})
+	return people
+}
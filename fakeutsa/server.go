@@ -0,0 +1,192 @@
+// Package fakeutsa emulates the UTSA directory flows the directory package depends on, populated with synthetic people,
+// so that the whole login, scrape and detail flow can be exercised without a network.
+package fakeutsa
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/samber/lo"
+)
+
+const (
+	authCookie  = ".ADAuthCookie"
+	tokenCookie = "__RequestVerificationToken"
+)
+
+// Server serves a fake UTSA directory, accepting a single set of credentials
+type Server struct {
+	Username string
+	Password string
+	People   []Person
+
+	mu       sync.Mutex
+	tokens   map[string]bool // Verification tokens handed out with the login form
+	sessions map[string]bool // Values of valid auth cookies
+	logins   int
+}
+
+// New creates a Server for the given credentials and people
+func New(username string, password string, people []Person) *Server {
+	return &Server{
+		Username: username,
+		Password: password,
+		People:   people,
+		tokens:   make(map[string]bool),
+		sessions: make(map[string]bool),
+	}
+}
+
+// Logins returns the number of successful logins so far
+func (s *Server) Logins() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.logins
+}
+
+// ExpireSessions invalidates every auth cookie handed out so far, as if they had all timed out
+func (s *Server) ExpireSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions = make(map[string]bool)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/directory/Directory" && r.Method == http.MethodGet:
+		s.requireAuth(w, r, s.serveIndex)
+	case r.URL.Path == "/directory/Account/Login" && r.Method == http.MethodGet:
+		s.serveLoginForm(w, r, "")
+	case r.URL.Path == "/directory/" && r.Method == http.MethodPost:
+		s.serveLoginSubmit(w, r)
+	case r.URL.Path == "/directory/Account/LogOff" && r.Method == http.MethodGet:
+		s.serveLogOff(w, r)
+	case r.URL.Path == "/directory/AdvancedSearch" && r.Method == http.MethodGet:
+		s.requireAuth(w, r, s.serveIndex)
+	case r.URL.Path == "/directory/SearchByLastName" && r.Method == http.MethodGet:
+		s.requireAuth(w, r, s.serveSearch)
+	case r.URL.Path == "/directory/Person_Detail" && r.Method == http.MethodGet:
+		s.requireAuth(w, r, s.serveDetail)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// requireAuth redirects to the login page, like the real directory, unless the request carries a valid auth cookie
+func (s *Server) requireAuth(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if cookie, err := r.Cookie(authCookie); err == nil {
+		s.mu.Lock()
+		valid := s.sessions[cookie.Value]
+		s.mu.Unlock()
+
+		if valid {
+			next(w, r)
+			return
+		}
+	}
+
+	query := url.Values{"ReturnUrl": {r.URL.RequestURI()}}
+	http.Redirect(w, r, "/directory/Account/Login?"+query.Encode(), http.StatusFound)
+}
+
+func (s *Server) serveIndex(w http.ResponseWriter, r *http.Request) {
+	render(w, http.StatusOK, indexTemplate, nil)
+}
+
+func (s *Server) serveLoginForm(w http.ResponseWriter, r *http.Request, validationError string) {
+	token := randomHex()
+	s.mu.Lock()
+	s.tokens[token] = true
+	s.mu.Unlock()
+
+	// Like ASP.NET's anti-forgery check, the form's token must be posted along with the matching cookie
+	http.SetCookie(w, &http.Cookie{Name: tokenCookie, Value: token, Path: "/directory", HttpOnly: true})
+	render(w, http.StatusOK, loginTemplate, map[string]string{"Token": token, "Error": validationError})
+}
+
+func (s *Server) serveLoginSubmit(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	// A missing or mismatched token is a server error on the real directory too
+	token := r.PostForm.Get(tokenCookie)
+	cookie, err := r.Cookie(tokenCookie)
+	s.mu.Lock()
+	validToken := s.tokens[token]
+	delete(s.tokens, token)
+	s.mu.Unlock()
+	if err != nil || !validToken || cookie.Value != token {
+		http.Error(w, "The required anti-forgery form field \"__RequestVerificationToken\" is not present.", http.StatusInternalServerError)
+		return
+	}
+
+	if r.PostForm.Get("myUTSAID") != s.Username || r.PostForm.Get("passphrase") != s.Password {
+		s.serveLoginForm(w, r, "The myUTSA ID or passphrase is incorrect.")
+		return
+	}
+
+	session := randomHex()
+	s.mu.Lock()
+	s.sessions[session] = true
+	s.logins++
+	s.mu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{Name: authCookie, Value: session, Path: "/", HttpOnly: true})
+	http.Redirect(w, r, "/directory/AdvancedSearch", http.StatusFound)
+}
+
+func (s *Server) serveLogOff(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(authCookie); err == nil {
+		s.mu.Lock()
+		delete(s.sessions, cookie.Value)
+		s.mu.Unlock()
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: authCookie, Value: "", Path: "/", MaxAge: -1})
+	http.Redirect(w, r, "/directory/Account/Login", http.StatusFound)
+}
+
+func (s *Server) serveSearch(w http.ResponseWriter, r *http.Request) {
+	letter := strings.ToUpper(r.URL.Query().Get("abc"))
+	people := lo.Filter(s.People, func(person Person, _ int) bool {
+		return letter != "" && strings.HasPrefix(strings.ToUpper(person.Entry.Name), letter)
+	})
+
+	render(w, http.StatusOK, searchTemplate, map[string]any{"Letter": letter, "People": people})
+}
+
+func (s *Server) serveDetail(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("abc")
+	person, found := lo.Find(s.People, func(person Person) bool {
+		return person.Entry.Id == id
+	})
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+
+	render(w, http.StatusOK, detailTemplate, person.Full)
+}
+
+// render executes a template, which can only fail on programming errors
+func render(w http.ResponseWriter, code int, tmpl *template.Template, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(code)
+	if err := tmpl.Execute(w, data); err != nil {
+		panic(err)
+	}
+}
+
+// randomHex returns a random 16 byte hex string, for tokens and sessions
+func randomHex() string {
+	value := make([]byte, 16)
+	rand.Read(value)
+	return hex.EncodeToString(value)
+}
@@ -0,0 +1,93 @@
+package fakeutsa
+
+import "html/template"
+
+// The templates only reproduce the markup the directory package relies upon, within a page resembling the real one
+
+const layout = `{{define "layout"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="utf-8" />
+    <title>{{template "title" .}} - UTSA Directory</title>
+</head>
+<body>
+    <nav class="navbar navbar-expand-lg">
+        <a class="navbar-brand" href="/directory/">UTSA Directory</a>
+        {{template "nav" .}}
+    </nav>
+    <div id="main" class="container">
+{{template "main" .}}
+    </div>
+</body>
+</html>
+{{end}}
+{{define "nav"}}<div class="dropdown-menu">
+            <a class="dropdown-item" href="/directory/AdvancedSearch">Advanced Search</a>
+            <a class="dropdown-item" href="/directory/Account/LogOff">Log Off</a>
+        </div>{{end}}`
+
+func page(content string) *template.Template {
+	return template.Must(template.Must(template.New("layout").Parse(layout)).Parse(content)).Lookup("layout")
+}
+
+var indexTemplate = page(`{{define "title"}}Advanced Search{{end}}
+{{define "main"}}        <form action="/directory/AdvancedSearch" method="get">
+            <input type="text" name="lastName" />
+        </form>{{end}}`)
+
+var loginTemplate = page(`{{define "title"}}Log In{{end}}
+{{define "nav"}}{{end}}
+{{define "main"}}        <form action="/directory/" method="post">
+            <input name="__RequestVerificationToken" type="hidden" value="{{.Token}}" />
+            <input name="myUTSAID" type="text" />
+            <input name="passphrase" type="password" />
+            {{with .Error}}<span class="field-validation-error">{{.}}</span>{{end}}
+            <input name="log-me-in" type="submit" value="Log In" />
+        </form>{{end}}`)
+
+var searchTemplate = page(`{{define "title"}}Search Results{{end}}
+{{define "main"}}        <h2>Last names beginning with &quot;{{.Letter}}&quot;</h2>
+        <table id="peopleTable" class="table table-striped">
+            <thead>
+                <tr><th>Name</th><th>Phone</th></tr>
+            </thead>
+            <tbody>
+{{- range .People}}
+                <tr>
+                    <td>
+                        <a class="fullName" href="/directory/Person_Detail?abc={{.Entry.Id}}">{{.Entry.Name}}</a>
+                        <br /><span class="jobtitle">{{.Entry.JobTitle}}</span>
+                        {{- with .Entry.Department}}
+                        <br /><span class="dept">{{.}}</span>{{end}}
+                        <br /><span class="college">{{.Entry.College}}</span>
+                    </td>
+                    <td><span class="phone">{{.Entry.Phone}}</span></td>
+                </tr>
+{{- end}}
+            </tbody>
+        </table>{{end}}`)
+
+var detailTemplate = page(`{{define "title"}}Person Detail{{end}}
+{{define "main"}}        <p><span class="nameBold"><strong>{{.Name}}</strong></span></p>
+        <table class="detail">
+            <tbody>
+                {{- with .Classification}}
+                <tr><th><strong>Classification:</strong></th><td>{{.}}</td></tr>{{end}}
+                {{- with .Title}}
+                <tr><th><strong>Title:</strong></th><td>{{.}}</td></tr>{{end}}
+                {{- with .Department}}
+                <tr><th><strong>Department:</strong></th><td>{{.}}</td></tr>{{end}}
+                {{- with .College}}
+                <tr><th><strong>College:</strong></th><td>{{.}}</td></tr>{{end}}
+                {{- with .Major}}
+                <tr><th><strong>Major:</strong></th><td>{{.}}</td></tr>{{end}}
+                {{- with .Email}}
+                <tr><th><strong>Email:</strong></th><td><a href="mailto:{{.}}">{{.}}</a></td></tr>{{end}}
+                {{- with .Phone}}
+                <tr><th><strong>Phone:</strong></th><td>{{.}}</td></tr>{{end}}
+                {{- with .MailingAddress}}
+                <tr><th><strong>Mailing Address:</strong></th><td>{{.}}</td></tr>{{end}}
+                {{- with .Building}}
+                <tr><th><strong>Building:</strong></th><td>{{.}}</td></tr>{{end}}
+            </tbody>
+        </table>{{end}}`)
@@ -0,0 +1,100 @@
+// Package metrics holds the Prometheus metrics of every outgoing request, rate limiter, cache lookup and unsubscribe.
+package metrics
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
+)
+
+const namespace = "unsubscribe"
+
+// Registry holds every metric below, along with the Go runtime and process collectors
+var Registry = prometheus.NewRegistry()
+
+var (
+	// Requests counts the requests sent, by simplified domain, method and status code ("error" if none was received)
+	Requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "requests_total",
+		Help:      "Requests sent, including retries, by domain, method and status code.",
+	}, []string{"domain", "method", "code"})
+
+	// RequestDuration observes how long each request took to be answered, by simplified domain
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "request_duration_seconds",
+		Help:      "Time taken for each request to be answered, by domain.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"domain"})
+
+	// InFlightWait observes how long each request waited for one of its domain's in-flight slots, before waiting on its rate limiter
+	InFlightWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "in_flight_wait_seconds",
+		Help:      "Time each request waited for a slot under its domain's cap on requests in flight, by domain.",
+		Buckets:   []float64{0, 0.01, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"domain"})
+
+	// LimiterWait observes how long each request waited on its domain's rate limiter
+	LimiterWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "limiter_wait_seconds",
+		Help:      "Time each request waited for a rate limiter token, by domain.",
+		Buckets:   []float64{0, 0.01, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"domain"})
+
+	// LimiterRate is the current rate of each domain's limiter, which adaptive rate limiting moves below the configured rate
+	LimiterRate = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "limiter_rate",
+		Help:      "Current requests per second allowed by each domain's rate limiter.",
+	}, []string{"domain"})
+
+	// CacheLookups counts directory and entry cache lookups, by kind and result (hit, miss, expired or stale)
+	CacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_lookups_total",
+		Help:      "Cache lookups by kind (directory, entry) and result (hit, miss, expired, stale).",
+	}, []string{"kind", "result"})
+
+	// Unsubscribes counts unsubscribe attempts by outcome (unsubscribed, skipped, dry_run or failed), the scla.ErrorType of a
+	// failure as its reason, and whether the email was a decoy
+	Unsubscribes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "unsubscribes_total",
+		Help:      "Unsubscribe attempts by outcome (unsubscribed, skipped, dry_run or failed), the type of error of a failure, and whether the email was a decoy.",
+	}, []string{"outcome", "reason", "decoy"})
+)
+
+func init() {
+	Registry.MustRegister(
+		Requests, RequestDuration, InFlightWait, LimiterWait, LimiterRate, CacheLookups, Unsubscribes,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// WriteText writes the current value of every metric in the Prometheus text format, for runs too short to be scraped
+func WriteText(w io.Writer) error {
+	families, err := Registry.Gather()
+	if err != nil {
+		return err
+	}
+
+	for _, family := range families {
+		if _, err := expfmt.MetricFamilyToText(w, family); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/time/rate"
+)
+
+// AdaptivePolicy describes how adaptive rate limiting reacts to a domain's responses.
+// A domain's rate is multiplied down on 429 and 503 responses or when its response times rise well above their average,
+// and added back to a little at a time on every other response, never exceeding the configured rate (AIMD).
+type AdaptivePolicy struct {
+	Decrease float64       // Factor the rate is multiplied by when backing off
+	Increase float64       // Fraction of the configured rate added back per healthy response
+	MinRate  rate.Limit    // The rate is never reduced below this
+	Cooldown time.Duration // Minimum time between two decreases, so a burst of errors only counts once
+
+	SlowFactor  float64 // Recent response times this many times above their long-term average count as rising
+	WarmupCount int     // Responses seen before rising response times are acted on
+}
+
+// DefaultAdaptivePolicy halves the rate when backing off, and takes 25 healthy responses to recover from a single decrease
+// (half of the configured rate, at 2% of it per response)
+var DefaultAdaptivePolicy = AdaptivePolicy{
+	Decrease:    0.5,
+	Increase:    0.02,
+	MinRate:     0.1,
+	Cooldown:    5 * time.Second,
+	SlowFactor:  2,
+	WarmupCount: 10,
+}
+
+// Smoothing of the moving averages of response times
+const (
+	shortLatencyWeight = 0.3
+	longLatencyWeight  = 0.05
+)
+
+// SetAdaptive enables adaptive rate limiting with the given policy, or disables it if nil, restoring every configured rate
+func (r *LimiterRegistry) SetAdaptive(policy *AdaptivePolicy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.adaptive = policy
+	if policy == nil {
+		for _, limiter := range r.limiters {
+			limiter.limiter.SetLimit(limiter.ceiling)
+		}
+	}
+}
+
+// Observe adjusts the rate of the given URL's domain to a response's status code and how long it took,
+// returning the domain's current rate. Without adaptive rate limiting, the rate is only returned.
+// Response times are only compared to those of requests with the same path, so that a mix of slow directory pages
+// and fast detail pages is not mistaken for rising latency.
+func (r *LimiterRegistry) Observe(rawUrl string, code int, duration time.Duration) rate.Limit {
+	domain := SimplifyUrlToDomain(rawUrl)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	limiter := r.get(domain)
+	current := limiter.limiter.Limit()
+	if r.adaptive == nil || limiter.ceiling == rate.Inf {
+		return current
+	}
+	policy := r.adaptive
+
+	// Compare recent response times to their long-term average, skipping the comparison until there are enough of them
+	kind := requestKind(rawUrl)
+	times, ok := limiter.latencies[kind]
+	if !ok {
+		times = &latency{}
+		limiter.latencies[kind] = times
+	}
+	seconds := duration.Seconds()
+	if times.samples == 0 {
+		times.short, times.long = seconds, seconds
+	} else {
+		times.short += (seconds - times.short) * shortLatencyWeight
+		times.long += (seconds - times.long) * longLatencyWeight
+	}
+	times.samples++
+	slow := times.samples > policy.WarmupCount && times.short > times.long*policy.SlowFactor
+
+	var reason string
+	switch {
+	case code == http.StatusTooManyRequests || code == http.StatusServiceUnavailable:
+		reason = http.StatusText(code)
+	case slow:
+		reason = "rising latency"
+	}
+
+	if reason != "" {
+		if time.Since(limiter.lastDecrease) < policy.Cooldown {
+			return current
+		}
+		limiter.lastDecrease = time.Now()
+
+		decreased := max(current*rate.Limit(policy.Decrease), policy.MinRate)
+		limiter.limiter.SetLimit(decreased)
+		log.Info().Str("domain", domain).Str("path", kind).Str("reason", reason).Float64("from", float64(current)).Float64("to", float64(decreased)).
+			Str("latency", time.Duration(times.short*float64(time.Second)).String()).Msg("Rate Limit Reduced")
+		return decreased
+	}
+
+	// Server errors are neither slow nor healthy, so the rate is left as is
+	if code >= 500 || current >= limiter.ceiling {
+		return current
+	}
+
+	increased := min(current+limiter.ceiling*rate.Limit(policy.Increase), limiter.ceiling)
+	limiter.limiter.SetLimit(increased)
+	if increased == limiter.ceiling {
+		log.Info().Str("domain", domain).Float64("rate", float64(increased)).Msg("Rate Limit Recovered")
+	}
+	return increased
+}
+
+// requestKind returns the path of a URL, which tells its kind of request apart; the query (such as a person's ID) does not.
+// A bare host has no path, and is a kind of its own.
+func requestKind(rawUrl string) string {
+	if !strings.Contains(rawUrl, "://") {
+		return ""
+	}
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Path)
+}
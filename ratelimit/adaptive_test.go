@@ -0,0 +1,122 @@
+package ratelimit
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// testPolicy is DefaultAdaptivePolicy without a cooldown, so each response can be acted on
+var testPolicy = AdaptivePolicy{
+	Decrease:    0.5,
+	Increase:    0.25,
+	MinRate:     0.5,
+	SlowFactor:  2,
+	WarmupCount: 5,
+}
+
+func newAdaptiveRegistry() *LimiterRegistry {
+	registry := NewLimiterRegistry(map[string]Limit{"utsa.edu": {Rate: 4, Burst: 5}})
+	registry.SetAdaptive(&testPolicy)
+	return registry
+}
+
+func TestAdaptiveBacksOffAndRecovers(t *testing.T) {
+	registry := newAdaptiveRegistry()
+
+	if limit := registry.Observe("www.utsa.edu", http.StatusTooManyRequests, time.Second); limit != 2 {
+		t.Fatalf("rate after a 429 = %v, expected 2", limit)
+	}
+	if limit := registry.Observe("www.utsa.edu", http.StatusServiceUnavailable, time.Second); limit != 1 {
+		t.Fatalf("rate after a 503 = %v, expected 1", limit)
+	}
+	for i := 0; i < 3; i++ {
+		registry.Observe("www.utsa.edu", http.StatusTooManyRequests, time.Second)
+	}
+	if limit := registry.Get("utsa.edu").Limit(); limit != testPolicy.MinRate {
+		t.Fatalf("rate after repeated 429s = %v, expected the minimum %v", limit, testPolicy.MinRate)
+	}
+
+	// Each healthy response adds back a quarter of the configured rate, up to the configured rate
+	expected := []rate.Limit{1.5, 2.5, 3.5, 4, 4}
+	for i, want := range expected {
+		if limit := registry.Observe("www.utsa.edu", http.StatusOK, time.Second); limit != want {
+			t.Errorf("rate after %d healthy responses = %v, expected %v", i+1, limit, want)
+		}
+	}
+
+	// The configured rate is still reported as the limit
+	if limits := registry.Limits(); limits["utsa.edu"].Rate != 4 {
+		t.Errorf("configured rate = %v, expected 4", limits["utsa.edu"].Rate)
+	}
+}
+
+func TestAdaptiveBacksOffOnRisingLatency(t *testing.T) {
+	registry := newAdaptiveRegistry()
+
+	// Slow but steady responses are normal, directory pages always take a while
+	for i := 0; i < 20; i++ {
+		if limit := registry.Observe("utsa.edu", http.StatusOK, 5*time.Second); limit != 4 {
+			t.Fatalf("rate after steady responses = %v, expected 4", limit)
+		}
+	}
+
+	// A sudden slowdown is not
+	var limit rate.Limit
+	for i := 0; i < 5; i++ {
+		limit = registry.Observe("utsa.edu", http.StatusOK, 30*time.Second)
+	}
+	if limit >= 4 {
+		t.Errorf("rate after rising latency = %v, expected it to be reduced", limit)
+	}
+}
+
+func TestAdaptiveCooldown(t *testing.T) {
+	registry := newAdaptiveRegistry()
+	policy := testPolicy
+	policy.Cooldown = time.Hour
+	registry.SetAdaptive(&policy)
+
+	registry.Observe("utsa.edu", http.StatusTooManyRequests, time.Second)
+	if limit := registry.Observe("utsa.edu", http.StatusTooManyRequests, time.Second); limit != 2 {
+		t.Errorf("rate after two 429s within the cooldown = %v, expected a single decrease to 2", limit)
+	}
+}
+
+func TestAdaptiveDisabled(t *testing.T) {
+	registry := newAdaptiveRegistry()
+	registry.Observe("utsa.edu", http.StatusTooManyRequests, time.Second)
+
+	// Disabling restores the configured rate, and responses no longer change it
+	registry.SetAdaptive(nil)
+	if limit := registry.Observe("utsa.edu", http.StatusTooManyRequests, time.Second); limit != 4 {
+		t.Errorf("rate with adaptive rate limiting disabled = %v, expected 4", limit)
+	}
+}
+
+func TestAdaptiveIgnoresMixOfSteadyLatencies(t *testing.T) {
+	registry := newAdaptiveRegistry()
+
+	// Directory pages are always slow and detail pages always fast, which is not rising latency however they are interleaved
+	for i := 0; i < 50; i++ {
+		url := "https://www.utsa.edu/directory/Person_Detail?abc=" + string(rune('a'+i%26))
+		duration := 100 * time.Millisecond
+		if i%10 >= 7 {
+			url, duration = "https://www.utsa.edu/directory/SearchByLastName", 10*time.Second
+		}
+		if limit := registry.Observe(url, http.StatusOK, duration); limit != 4 {
+			t.Fatalf("rate after %d steady responses = %v, expected 4", i+1, limit)
+		}
+	}
+
+	// Each kind of request is still watched for rising latency
+	var limit rate.Limit
+	for i := 0; i < 5; i++ {
+		limit = registry.Observe("https://www.utsa.edu/directory/Person_Detail?abc=a", http.StatusOK, 2*time.Second)
+	}
+	if limit >= 4 {
+		t.Errorf("rate after rising detail page latency = %v, expected it to be reduced", limit)
+	}
+}
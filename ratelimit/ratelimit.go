@@ -0,0 +1,235 @@
+// Package ratelimit holds the per-domain token bucket limiters that every outgoing request waits on.
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/net/publicsuffix"
+	"golang.org/x/time/rate"
+)
+
+// Limit is the rate and burst of a token bucket, along with how many requests may be in flight at once
+type Limit struct {
+	Rate        rate.Limit
+	Burst       int
+	MaxInFlight int // Requests sent but not yet fully read, 0 for no limit
+}
+
+// DefaultLimit is given to domains that have not been given a limit of their own
+var DefaultLimit = Limit{Rate: 1, Burst: 3, MaxInFlight: 4}
+
+// DefaultLimits are the known limits of UTSA and the SCLA, which every web.Client's registry starts with.
+// Directory pages take a long time to generate, so few are requested at once.
+var DefaultLimits = map[string]Limit{
+	"utsa.edu":    {Rate: 2, Burst: 5, MaxInFlight: 3},
+	"thescla.org": {Rate: 3, Burst: 7, MaxInFlight: 5},
+}
+
+// LimiterRegistry holds a limiter for each registrable domain, creating them as new domains are seen.
+// It is safe for concurrent use.
+type LimiterRegistry struct {
+	mu       sync.Mutex
+	limiters map[string]*domainLimiter
+	adaptive *AdaptivePolicy // Adjusts each limiter's rate to its responses when not nil, see Observe
+}
+
+// domainLimiter is the limiter of a single domain, along with what adaptive rate limiting knows of it
+type domainLimiter struct {
+	limiter  *rate.Limiter
+	ceiling  rate.Limit    // The configured rate, which adaptive rate limiting never exceeds
+	inFlight chan struct{} // Semaphore holding a slot for each request in flight, nil if unlimited
+
+	latencies    map[string]*latency // Response times by request path, as some pages are always far slower than others
+	lastDecrease time.Time
+}
+
+// latency holds the moving averages of the response times of a single kind of request
+type latency struct {
+	short   float64 // Quickly moving average, in seconds
+	long    float64 // Slowly moving average, in seconds
+	samples int
+}
+
+// newDomainLimiter creates the limiter of a domain, starting at its ceiling
+func newDomainLimiter(limit Limit) *domainLimiter {
+	return &domainLimiter{
+		limiter:   rate.NewLimiter(limit.Rate, limit.Burst),
+		ceiling:   limit.Rate,
+		inFlight:  newSemaphore(limit.MaxInFlight),
+		latencies: make(map[string]*latency),
+	}
+}
+
+// newSemaphore creates a semaphore with the given number of slots, or nil if it is not positive
+func newSemaphore(slots int) chan struct{} {
+	if slots <= 0 {
+		return nil
+	}
+	return make(chan struct{}, slots)
+}
+
+// NewLimiterRegistry creates a registry with the given limits, keyed by domain
+func NewLimiterRegistry(limits map[string]Limit) *LimiterRegistry {
+	r := &LimiterRegistry{limiters: make(map[string]*domainLimiter, len(limits))}
+	for domain, limit := range limits {
+		r.SetLimit(domain, limit)
+	}
+	return r
+}
+
+// Get returns the limiter for the domain of the given host or URL, creating one with the DefaultLimit if it does not exist
+func (r *LimiterRegistry) Get(host string) *rate.Limiter {
+	domain := SimplifyUrlToDomain(host)
+	if domain != host {
+		log.Debug().Str("host", host).Str("domain", domain).Msg("Domain Simplified")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.get(domain).limiter
+}
+
+// get returns the limiter of an already simplified domain, creating it if it does not exist; r.mu must be held
+func (r *LimiterRegistry) get(domain string) *domainLimiter {
+	limiter, ok := r.limiters[domain]
+	if !ok {
+		limiter = newDomainLimiter(DefaultLimit)
+		r.limiters[domain] = limiter
+		log.Debug().Str("domain", domain).Msg("New Limiter Created")
+	}
+	return limiter
+}
+
+// SetLimit replaces the limit of the domain of the given host or URL, creating its limiter if it does not exist.
+// With adaptive rate limiting, the rate becomes the ceiling the limiter starts at and recovers to.
+func (r *LimiterRegistry) SetLimit(host string, limit Limit) {
+	domain := SimplifyUrlToDomain(host)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	limiter, ok := r.limiters[domain]
+	if !ok {
+		r.limiters[domain] = newDomainLimiter(limit)
+		return
+	}
+
+	limiter.ceiling = limit.Rate
+	limiter.limiter.SetLimit(limit.Rate)
+	limiter.limiter.SetBurst(limit.Burst)
+
+	// Requests already in flight release their slot into the semaphore they acquired it from
+	if cap(limiter.inFlight) != max(limit.MaxInFlight, 0) {
+		limiter.inFlight = newSemaphore(limit.MaxInFlight)
+	}
+}
+
+// Limits returns the configured limit of every domain with a limiter, which adaptive rate limiting may currently be below
+func (r *LimiterRegistry) Limits() map[string]Limit {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	limits := make(map[string]Limit, len(r.limiters))
+	for domain, limiter := range r.limiters {
+		limits[domain] = Limit{Rate: limiter.ceiling, Burst: limiter.limiter.Burst(), MaxInFlight: cap(limiter.inFlight)}
+	}
+	return limits
+}
+
+// Wait waits for a token from the limiter of the given host's domain, see Wait
+func (r *LimiterRegistry) Wait(ctx context.Context, host string) error {
+	return Wait(r.Get(host), ctx)
+}
+
+// Acquire waits for one of the in-flight slots of the given host's domain, returning early with the context's error if it is done first.
+// The slot is held until the returned function is called, which may safely be called more than once.
+func (r *LimiterRegistry) Acquire(ctx context.Context, host string) (func(), error) {
+	r.mu.Lock()
+	slots := r.get(SimplifyUrlToDomain(host)).inFlight
+	r.mu.Unlock()
+
+	if slots == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case slots <- struct{}{}:
+		return sync.OnceFunc(func() { <-slots }), nil
+	default:
+	}
+
+	log.Debug().Str("host", host).Int("inFlight", cap(slots)).Msg("Waiting for In-Flight Request")
+	select {
+	case slots <- struct{}{}:
+		return sync.OnceFunc(func() { <-slots }), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// SimplifyUrlToDomain transforms a host or url into its registrable domain (eTLD+1), using the public suffix list.
+// This is not the same as the host, as it removes subdomains (www, asap, etc.)
+// This helps me group together domains that are related to eachother, such as those at UTSA.
+// IP addresses and single label hosts (such as localhost) have no registrable domain, and are returned as is, without the port.
+func SimplifyUrlToDomain(host string) string {
+	if strings.Contains(host, "://") {
+		if parsed, err := url.Parse(host); err == nil {
+			host = parsed.Host
+		}
+	}
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	if net.ParseIP(host) != nil || !strings.Contains(host, ".") {
+		return host
+	}
+
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		// The host is itself a public suffix, such as github.io
+		return host
+	}
+	return domain
+}
+
+// Wait waits for a token from the limiter, returning early with the context's error if it is done first.
+// A token that cannot be had before the context's deadline is not waited for.
+func Wait(limiter *rate.Limiter, ctx context.Context) error {
+	r := limiter.Reserve()
+	if !r.OK() {
+		log.Warn().Msg("Rate Limit Exceeded")
+		return errors.New("rate limit burst exceeded")
+	}
+
+	delay := r.Delay()
+	if delay == 0 {
+		return nil
+	}
+
+	// Give the token back if it would only be available after the deadline
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		r.Cancel()
+		return context.DeadlineExceeded
+	}
+
+	// Wait for the limiter
+	log.Debug().Str("delay", delay.String()).Msg("Waiting")
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		r.Cancel()
+		return ctx.Err()
+	}
+}
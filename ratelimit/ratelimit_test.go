@@ -0,0 +1,168 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestSimplifyUrlToDomain(t *testing.T) {
+	cases := map[string]string{
+		"www.utsa.edu":                     "utsa.edu",
+		"asap.utsa.edu":                    "utsa.edu",
+		"https://www.utsa.edu/directory/":  "utsa.edu",
+		"www2.thescla.org:443":             "thescla.org",
+		"WWW.UTSA.EDU.":                    "utsa.edu",
+		"www.example.co.uk":                "example.co.uk",
+		"user.github.io":                   "user.github.io",
+		"http://127.0.0.1:8081/index.html": "127.0.0.1",
+		"127.0.0.1:8082":                   "127.0.0.1",
+		"localhost:8081":                   "localhost",
+		"[::1]:8081":                       "::1",
+	}
+
+	for input, expected := range cases {
+		if actual := SimplifyUrlToDomain(input); actual != expected {
+			t.Errorf("SimplifyUrlToDomain(%q) = %q, expected %q", input, actual, expected)
+		}
+	}
+}
+
+func TestRegistrySharesLimiterAcrossSubdomains(t *testing.T) {
+	registry := NewLimiterRegistry(map[string]Limit{"utsa.edu": {Rate: 2, Burst: 5}})
+
+	limiter := registry.Get("www.utsa.edu")
+	if limiter != registry.Get("https://asap.utsa.edu/") {
+		t.Error("subdomains of utsa.edu were given different limiters")
+	}
+	if limiter.Limit() != 2 || limiter.Burst() != 5 {
+		t.Errorf("limit = %v:%d, expected 2:5", limiter.Limit(), limiter.Burst())
+	}
+
+	created := registry.Get("example.com")
+	if created.Limit() != DefaultLimit.Rate || created.Burst() != DefaultLimit.Burst {
+		t.Errorf("new limiter = %v:%d, expected the default %v:%d", created.Limit(), created.Burst(), DefaultLimit.Rate, DefaultLimit.Burst)
+	}
+}
+
+func TestRegistryIsSafeForConcurrentUse(t *testing.T) {
+	registry := NewLimiterRegistry(nil)
+	hosts := []string{"www.utsa.edu", "thescla.org", "example.com", "127.0.0.1:8081"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			host := hosts[i%len(hosts)]
+			registry.SetLimit(host, Limit{Rate: rate.Inf, Burst: 1})
+			registry.Get(host)
+			registry.Limits()
+		}(i)
+	}
+	wg.Wait()
+
+	if limits := registry.Limits(); len(limits) != len(hosts) {
+		t.Errorf("registry has %d limiters, expected %d: %v", len(limits), len(hosts), limits)
+	}
+}
+
+func TestWaitIsCanceled(t *testing.T) {
+	limiter := rate.NewLimiter(rate.Every(time.Hour), 1)
+	if err := Wait(limiter, context.Background()); err != nil {
+		t.Fatalf("first token: %v", err)
+	}
+
+	// The next token is an hour away, so only the cancellation can end the wait
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	start := time.Now()
+	if err := Wait(limiter, ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, expected context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("wait took %s after being canceled", elapsed)
+	}
+}
+
+func TestWaitHonorsDeadline(t *testing.T) {
+	limiter := rate.NewLimiter(rate.Every(time.Hour), 1)
+	limiter.Allow()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := Wait(limiter, ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, expected context.DeadlineExceeded", err)
+	}
+
+	// The token that could not be waited for is given back
+	if tokens := limiter.Tokens(); tokens < -0.01 {
+		t.Errorf("tokens = %f, expected the reservation to be canceled", tokens)
+	}
+}
+
+func TestAcquireCapsInFlight(t *testing.T) {
+	registry := NewLimiterRegistry(map[string]Limit{"utsa.edu": {Rate: rate.Inf, Burst: 1, MaxInFlight: 2}})
+
+	first, err := registry.Acquire(context.Background(), "www.utsa.edu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := registry.Acquire(context.Background(), "asap.utsa.edu"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both slots are held, so a third request waits until one is released
+	acquired := make(chan func())
+	go func() {
+		release, err := registry.Acquire(context.Background(), "utsa.edu")
+		if err != nil {
+			t.Error(err)
+		}
+		acquired <- release
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("acquired a third slot while two of two were held")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// Releasing twice only frees a single slot
+	first()
+	first()
+	select {
+	case release := <-acquired:
+		release()
+	case <-time.After(time.Second):
+		t.Fatal("slot was not acquired after one was released")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := registry.Acquire(ctx, "utsa.edu"); err != nil {
+		t.Fatalf("slot freed by the third request was not available: %v", err)
+	}
+	if _, err := registry.Acquire(ctx, "utsa.edu"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, expected context.DeadlineExceeded while every slot is held", err)
+	}
+}
+
+func TestAcquireUnlimited(t *testing.T) {
+	registry := NewLimiterRegistry(map[string]Limit{"utsa.edu": {Rate: rate.Inf, Burst: 1}})
+	for i := 0; i < 100; i++ {
+		if _, err := registry.Acquire(context.Background(), "utsa.edu"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Setting a cap applies to new requests
+	registry.SetLimit("utsa.edu", Limit{Rate: rate.Inf, Burst: 1, MaxInFlight: 1})
+	if limits := registry.Limits(); limits["utsa.edu"].MaxInFlight != 1 {
+		t.Errorf("max in flight = %d, expected 1", limits["utsa.edu"].MaxInFlight)
+	}
+}
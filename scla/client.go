@@ -0,0 +1,61 @@
+// Package scla submits unsubscribe requests to the SCLA's Marketo lead capture form.
+package scla
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/icrowley/fake"
+	"github.com/samber/lo"
+
+	"unsubscribe/web"
+)
+
+// State stores the unsubscribe Record of each email.
+// GetRecord returns false if no attempt has been recorded for the email.
+type State interface {
+	GetRecord(email string) (*Record, bool, error)
+	PutRecord(record *Record) error
+}
+
+// Client unsubscribes emails using the given web client, recording progress in the given state
+type Client struct {
+	web   *web.Client
+	state State
+
+	// Form holds the parameters of the unsubscribe form, defaulting to DefaultForm
+	Form FormConfig
+
+	// DryRun builds each unsubscribe form without submitting it or recording anything
+	DryRun bool
+	// DryRunOutput receives each form built in dry-run mode as a line of JSON, if set
+	DryRunOutput io.Writer
+	dryRunMu     sync.Mutex
+}
+
+// NewClient creates an SCLA Client
+func NewClient(webClient *web.Client, state State) *Client {
+	return &Client{web: webClient, state: state, Form: DefaultForm}
+}
+
+// ApplySclaHeaders applies headers to a request for thescla.org
+func ApplySclaHeaders(req *http.Request) {
+	req.Header.Set("Origin", fmt.Sprintf("%s://%s", req.URL.Scheme, req.URL.Host))
+	req.Header.Set("Accept", "application/json, text/javascript, */*; q=0.01")
+	req.Header.Set("Accept-Language", "en-US,en;q=0.5")
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+}
+
+// RandBool returns a random boolean
+func RandBool() bool {
+	return rand.Uint64()&1 == 1
+}
+
+// FakeEmail generates a fake email address
+func FakeEmail() string {
+	return strings.ToLower(fmt.Sprintf("%s.%s@%sutsa.edu", fake.FirstName(), fake.LastName(), lo.Ternary(RandBool(), "my.", "")))
+}
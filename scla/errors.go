@@ -0,0 +1,53 @@
+package scla
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+)
+
+type ChecksumMissingError [32]byte
+type ChecksumInvalidError [32]byte
+type UnsubscribeRejectedError string
+type UnsubscribeUnexpectedError struct {
+	Message string
+	Code    int
+}
+
+func (e ChecksumMissingError) Error() string {
+	return fmt.Sprintf("checksum missing: %x", []byte(e[:]))
+}
+
+func (e ChecksumInvalidError) Error() string {
+	return fmt.Sprintf("checksum invalid: %x", []byte(e[:]))
+}
+
+func (e UnsubscribeRejectedError) Error() string {
+	return fmt.Sprintf("rejected: %s", string(e))
+}
+
+func (e UnsubscribeUnexpectedError) Error() string {
+	return fmt.Sprintf("unexpected error: %s", lo.Substring(e.Message, 0, 50))
+}
+
+// ErrorType names the kind of error an unsubscribe attempt failed with, for recording and reporting failures
+func ErrorType(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, new(ChecksumMissingError)):
+		return "checksum_missing"
+	case errors.As(err, new(ChecksumInvalidError)):
+		return "checksum_invalid"
+	case errors.As(err, new(UnsubscribeRejectedError)):
+		return "rejected"
+	case errors.As(err, new(UnsubscribeUnexpectedError)):
+		return "unexpected"
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return "canceled"
+	default:
+		return "request"
+	}
+}
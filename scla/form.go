@@ -0,0 +1,115 @@
+package scla
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+// FormConfig holds the Marketo parameters of the SCLA's unsubscribe form
+type FormConfig struct {
+	BaseUrl      string `yaml:"base_url"`
+	MktTok       string `yaml:"mkt_tok"` // No idea what this is, but it doesn't seem to change?
+	MunchkinId   string `yaml:"munchkin_id"`
+	FormId       string `yaml:"form_id"`
+	FormVid      string `yaml:"form_vid"`
+	LpId         string `yaml:"lp_id"`
+	SubId        string `yaml:"sub_id"`
+	FollowupLpId string `yaml:"followup_lp_id"`
+}
+
+// DefaultForm is the unsubscribe form as captured from the SCLA's unsubscribe page
+var DefaultForm = FormConfig{
+	BaseUrl:      "http://www2.thescla.org",
+	MktTok:       "ODM5LU1PTC01NTIAAAGQRiDbOUWzUhLliVDxTHjxLfZDD1y0MxC47Wf_1C9UTbwEej3Tckhn_QteZR7p5Mpl3_f0ioPUyQ8XUceJ9a0PiOUJb_O3YIj8PwKNQEm4SseaSw",
+	MunchkinId:   "839-MOL-552",
+	FormId:       "1",
+	FormVid:      "1",
+	LpId:         "1",
+	SubId:        "98",
+	FollowupLpId: "2",
+}
+
+// Validate returns an error if any parameter the form requires is missing
+func (f FormConfig) Validate() error {
+	if _, err := url.ParseRequestURI(f.BaseUrl); err != nil {
+		return fmt.Errorf("invalid scla base url %q: %w", f.BaseUrl, err)
+	}
+
+	missing := lo.Keys(lo.PickBy(map[string]string{
+		"mkt_tok":        f.MktTok,
+		"munchkin_id":    f.MunchkinId,
+		"form_id":        f.FormId,
+		"form_vid":       f.FormVid,
+		"lp_id":          f.LpId,
+		"sub_id":         f.SubId,
+		"followup_lp_id": f.FollowupLpId,
+	}, func(_ string, value string) bool {
+		return value == ""
+	}))
+	if len(missing) > 0 {
+		return fmt.Errorf("missing form parameters: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// SaveUrl is the lead capture endpoint the form is submitted to
+func (f FormConfig) SaveUrl() string {
+	return f.BaseUrl + "/index.php/leadCapture/save2"
+}
+
+// PageUrl is the unsubscribe page the form lives on
+func (f FormConfig) PageUrl() string {
+	return f.BaseUrl + "/UnsubscribePage.html"
+}
+
+// BuildForm builds the unsubscribe form values for an email, including the checksum the form requires
+func (f FormConfig) BuildForm(email string) (url.Values, [32]byte) {
+	// Build referrer URL
+	referrerUrl, _ := url.Parse(f.PageUrl())
+	query := referrerUrl.Query()
+	query.Add("mkt_unsubscribe", "1")
+	query.Add("mkt_tok", f.MktTok)
+	referrerUrl.RawQuery = query.Encode()
+
+	// Build lpUrl
+	lpUrl := fmt.Sprintf("http://%s.mktoweb.com/lp/%s/UnsubscribePage.html?cr={creative}&kw={keyword}", f.MunchkinId, f.MunchkinId)
+
+	values := url.Values{
+		"Email":         {email},
+		"Unsubscribed":  {"Yes"},
+		"formid":        {f.FormId},
+		"lpId":          {f.LpId},
+		"subId":         {f.SubId},
+		"munchkinId":    {f.MunchkinId},
+		"lpurl":         {lpUrl},
+		"followupLpId":  {f.FollowupLpId},
+		"cr":            {""},
+		"kw":            {""},
+		"q":             {""},
+		"_mkt_trk":      {""},
+		"formVid":       {f.FormVid},
+		"mkt_tok":       {f.MktTok},
+		"_mktoReferrer": {referrerUrl.String()},
+	}
+
+	// Grab checksum fields
+	fields := make([]string, 0, len(values))
+	for key, _ := range values {
+		fields = append(fields, key)
+	}
+	values.Set("checksumFields", strings.Join(fields, ","))
+
+	// Calculate checksum
+	checksum := sha256.Sum256([]byte(strings.Join(
+		lo.Map(fields, func(field string, _ int) string {
+			return values.Get(field)
+		}), "|")))
+
+	values.Set("checksum", fmt.Sprintf("%x", checksum))
+	return values, checksum
+}
@@ -0,0 +1,80 @@
+package scla
+
+import "time"
+
+// Status is the outcome of the attempts to unsubscribe an email so far
+type Status string
+
+const (
+	StatusPending      Status = "pending"      // An attempt was started, but its outcome is unknown
+	StatusUnsubscribed Status = "unsubscribed" // The unsubscribe was confirmed
+	StatusRejected     Status = "rejected"     // The form responded with a rejection
+	StatusFailed       Status = "failed"       // The attempt failed for any other reason
+)
+
+// Outcome is what a call to TryUnsubscribe did with an email
+type Outcome string
+
+const (
+	OutcomeUnsubscribed Outcome = "unsubscribed" // The form was submitted, and the unsubscribe confirmed
+	OutcomeSkipped      Outcome = "skipped"      // The email was already unsubscribed, so nothing was sent
+	OutcomeDryRun       Outcome = "dry_run"      // The form was only built and written out, as the client is in dry-run mode
+	OutcomeFailed       Outcome = "failed"       // The attempt failed, see the error returned alongside it
+)
+
+// Record is everything known about the attempts to unsubscribe a single email
+type Record struct {
+	Email        string    `json:"email"`
+	Status       Status    `json:"status"`
+	Attempts     int       `json:"attempts"`
+	FirstAttempt time.Time `json:"firstAttempt"`
+	LastAttempt  time.Time `json:"lastAttempt"`
+
+	// The error of the last failed attempt, cleared on success
+	LastError     string `json:"lastError,omitempty"`
+	LastErrorType string `json:"lastErrorType,omitempty"` // As returned by ErrorType
+
+	// Fields from the ConfirmationResponse of the successful attempt
+	FormId      string `json:"formId,omitempty"`
+	FollowUpUrl string `json:"followUpUrl,omitempty"`
+	AliId       string `json:"aliId,omitempty"`
+}
+
+// start records the beginning of a new attempt
+func (r *Record) start(now time.Time) {
+	if r.Attempts == 0 {
+		r.FirstAttempt = now
+	}
+	r.Attempts++
+	r.LastAttempt = now
+	r.Status = StatusPending
+}
+
+// fail records the error an attempt failed with
+func (r *Record) fail(err error) {
+	r.LastError = err.Error()
+	r.LastErrorType = ErrorType(err)
+
+	switch r.LastErrorType {
+	case "rejected":
+		r.Status = StatusRejected
+	case "canceled":
+		// The request may or may not have been received, so the outcome stays unknown
+		r.Status = StatusPending
+	default:
+		r.Status = StatusFailed
+	}
+}
+
+// succeed records the confirmation of a successful attempt
+func (r *Record) succeed(confirmation *ConfirmationResponse) {
+	r.Status = StatusUnsubscribed
+	r.LastError = ""
+	r.LastErrorType = ""
+
+	if confirmation != nil {
+		r.FormId = confirmation.FormId
+		r.FollowUpUrl = confirmation.FollowUpUrl
+		r.AliId = confirmation.AliId
+	}
+}
@@ -0,0 +1,206 @@
+package scla
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+
+	"unsubscribe/metrics"
+)
+
+// dryRunForm is what is written out for each form in dry-run mode
+type dryRunForm struct {
+	Email  string     `json:"email"`
+	Decoy  bool       `json:"decoy"` // Made up to hide the real emails, so not anyone's to unsubscribe
+	Url    string     `json:"url"`
+	Values url.Values `json:"values"`
+}
+
+// writeDryRun logs the form that would have been submitted, and writes it to DryRunOutput if set
+func (c *Client) writeDryRun(email string, decoy bool, values url.Values) error {
+	log.Info().Str("email", email).Bool("decoy", decoy).Str("checksum", values.Get("checksum")).Str("checksumFields", values.Get("checksumFields")).
+		Msg("Dry Run: Unsubscribe Form Built")
+	if c.DryRunOutput == nil {
+		return nil
+	}
+
+	marshalled, err := json.Marshal(dryRunForm{Email: email, Decoy: decoy, Url: c.Form.SaveUrl(), Values: values})
+	if err != nil {
+		return err
+	}
+
+	c.dryRunMu.Lock()
+	defer c.dryRunMu.Unlock()
+	_, err = c.DryRunOutput.Write(append(marshalled, '\n'))
+	return err
+}
+
+// Unsubscribe submits the unsubscribe form for an email, mapping known error responses to typed errors.
+// In dry-run mode, the form is only written out and a nil confirmation is returned.
+func (c *Client) Unsubscribe(ctx context.Context, email string) (*ConfirmationResponse, error) {
+	return c.submit(ctx, email, false)
+}
+
+// submit is Unsubscribe, with dry-run forms of decoys marked as such
+func (c *Client) submit(ctx context.Context, email string, decoy bool) (*ConfirmationResponse, error) {
+	values, checksum := c.Form.BuildForm(email)
+	if c.DryRun {
+		return nil, c.writeDryRun(email, decoy, values)
+	}
+
+	// Make request
+	request, _ := http.NewRequestWithContext(ctx, "POST", c.Form.SaveUrl(), strings.NewReader(values.Encode()))
+	request.Header.Set("Referer", c.Form.PageUrl()+"?mkt_unsubscribe=1")
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("X-Requested-With", "XMLHttpRequest")
+	ApplySclaHeaders(request)
+
+	// Send request
+	response, body, err := c.web.DoRequest(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != 200 {
+		// If JSON returned, parse the message for the error
+		contentType := response.Header.Get("Content-Type")
+		if !strings.Contains(contentType, "application/json") {
+			return nil, UnsubscribeUnexpectedError{Message: string(body), Code: response.StatusCode}
+		}
+
+		// Parse the JSON
+		var errorResponse ErrorResponse
+		err := json.Unmarshal(body, &errorResponse)
+		if err != nil {
+			log.Error().Err(err).Msg("Error parsing error response")
+			return nil, UnsubscribeUnexpectedError{Message: string(body), Code: response.StatusCode}
+		}
+
+		switch errorResponse.Message {
+		case "checksum invalid":
+			return nil, ChecksumInvalidError(checksum)
+		case "checksum missing":
+			return nil, ChecksumMissingError(checksum)
+		case "Rejected":
+			return nil, UnsubscribeRejectedError(errorResponse.Message)
+		}
+
+		log.Error().Str("content-type", contentType).Str("body", string(body)).Msg("Unknown Error")
+		return nil, UnsubscribeUnexpectedError{Message: string(body), Code: response.StatusCode}
+	}
+
+	var confirmation ConfirmationResponse
+	json.Unmarshal(body, &confirmation)
+	return &confirmation, nil
+}
+
+// countOutcome counts an unsubscribe attempt in the metrics, with the ErrorType of a failure as its reason
+func countOutcome(outcome Outcome, err error, decoy bool) {
+	reason := ""
+	if err != nil {
+		reason = ErrorType(err)
+	}
+	metrics.Unsubscribes.WithLabelValues(string(outcome), reason, strconv.FormatBool(decoy)).Inc()
+}
+
+// UnsubscribeDecoy submits the unsubscribe form for a made-up email, such as one from FakeEmail.
+// Its outcome is counted in the metrics under decoy="true", but not recorded in the state, as it will never be resumed.
+func (c *Client) UnsubscribeDecoy(ctx context.Context, email string) (Outcome, error) {
+	if _, err := c.submit(ctx, email, true); err != nil {
+		countOutcome(OutcomeFailed, err, true)
+		return OutcomeFailed, errors.Wrap(err, "failed to unsubscribe decoy email")
+	}
+
+	outcome := OutcomeUnsubscribed
+	if c.DryRun {
+		outcome = OutcomeDryRun
+	}
+	countOutcome(outcome, nil, true)
+	return outcome, nil
+}
+
+// NormalizeEmail lowercases an email, so that the same address is always stored under the same key
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// GetRecord returns the unsubscribe record of an email, or a new pending record if there is none
+func (c *Client) GetRecord(email string) (*Record, error) {
+	email = NormalizeEmail(email)
+	record, found, err := c.state.GetRecord(email)
+	if err != nil {
+		return nil, err
+	} else if !found {
+		return &Record{Email: email, Status: StatusPending}, nil
+	}
+	return record, nil
+}
+
+// CheckEmail checks if an email is unsubscribed in the database
+func (c *Client) CheckEmail(email string) (bool, error) {
+	record, err := c.GetRecord(email)
+	if err != nil {
+		return false, err
+	}
+	return record.Status == StatusUnsubscribed, nil
+}
+
+// TryUnsubscribe unsubscribes an email unless it is already marked as unsubscribed, recording the attempt and its outcome.
+// In dry-run mode, the form is only written out and OutcomeDryRun is returned, so it is never mistaken for an unsubscribe.
+func (c *Client) TryUnsubscribe(ctx context.Context, email string) (Outcome, error) {
+	// Check if the email is already unsubscribed
+	record, err := c.GetRecord(email)
+	if err != nil {
+		return OutcomeFailed, errors.Wrap(err, "failed to check if email is unsubscribed")
+	}
+	log.Debug().Str("email", email).Str("status", string(record.Status)).Int("attempts", record.Attempts).Msg("Checking if email is unsubscribed")
+
+	// If the email is already unsubscribed, return
+	if record.Status == StatusUnsubscribed {
+		countOutcome(OutcomeSkipped, nil, false)
+		return OutcomeSkipped, nil
+	}
+
+	// Nothing is sent in dry-run mode, so there is nothing to record either
+	if c.DryRun {
+		if _, err := c.Unsubscribe(ctx, email); err != nil {
+			return OutcomeFailed, errors.Wrap(err, "failed to write dry run form")
+		}
+		countOutcome(OutcomeDryRun, nil, false)
+		return OutcomeDryRun, nil
+	}
+
+	// Record the attempt before making it, so that it is known even if the outcome never is
+	record.start(time.Now())
+	if err := c.state.PutRecord(record); err != nil {
+		return OutcomeFailed, errors.Wrap(err, "failed to record unsubscribe attempt")
+	}
+
+	// Try to unsubscribe the email
+	confirmation, err := c.Unsubscribe(ctx, email)
+	if err != nil {
+		countOutcome(OutcomeFailed, err, false)
+		record.fail(err)
+		if putErr := c.state.PutRecord(record); putErr != nil {
+			log.Err(putErr).Str("email", email).Msg("Failed to record unsubscribe failure")
+		}
+		return OutcomeFailed, errors.Wrap(err, "failed to unsubscribe email")
+	}
+
+	// If the email was successfully unsubscribed, mark it as such
+	countOutcome(OutcomeUnsubscribed, nil, false)
+	record.succeed(confirmation)
+	err = c.state.PutRecord(record)
+	if err != nil {
+		return OutcomeUnsubscribed, errors.Wrap(err, "failed to mark email as unsubscribed")
+	}
+
+	return OutcomeUnsubscribed, nil
+}
@@ -0,0 +1,355 @@
+package scla_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"golang.org/x/time/rate"
+
+	"unsubscribe/fakemarketo"
+	"unsubscribe/metrics"
+	"unsubscribe/ratelimit"
+	"unsubscribe/scla"
+	"unsubscribe/store"
+	"unsubscribe/web"
+)
+
+// tamperTransport edits each submitted form before passing it on, as a buggy client might
+type tamperTransport struct {
+	next   http.RoundTripper
+	tamper func(values url.Values)
+}
+
+func (t tamperTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, err
+	}
+	t.tamper(values)
+
+	encoded := values.Encode()
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(strings.NewReader(encoded))
+	req.ContentLength = int64(len(encoded))
+	return t.next.RoundTrip(req)
+}
+
+// testRetryPolicy retries as often as the default policy, but without waiting long between attempts
+var testRetryPolicy = web.RetryPolicy{
+	MaxAttempts:     web.DefaultRetryPolicy.MaxAttempts,
+	BaseDelay:       time.Millisecond,
+	MaxDelay:        10 * time.Millisecond,
+	RetryableStatus: web.DefaultRetryPolicy.RetryableStatus,
+}
+
+// newFakeMarketo starts a fake lead capture endpoint, returning it along with a client pointed at it.
+// If tamper is not nil, every form is passed through it before being sent.
+func newFakeMarketo(t *testing.T, behavior fakemarketo.Behavior, tamper func(values url.Values)) (*fakemarketo.Server, *scla.Client) {
+	t.Helper()
+
+	fake := fakemarketo.New(behavior)
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	var transport http.RoundTripper = server.Client().Transport
+	if tamper != nil {
+		transport = tamperTransport{next: transport, tamper: tamper}
+	}
+
+	// Requests to the fake server need not be rate limited, nor wait long between retries
+	webClient := web.NewClientWithTransport(transport)
+	webClient.Limiters.SetLimit(server.URL, ratelimit.Limit{Rate: rate.Inf, Burst: 1})
+	webClient.RetryPolicies[ratelimit.SimplifyUrlToDomain(server.URL)] = testRetryPolicy
+
+	client := scla.NewClient(webClient, store.NewMemoryStore())
+	client.Form.BaseUrl = server.URL
+	return fake, client
+}
+
+func TestUnsubscribeSucceeds(t *testing.T) {
+	fake, client := newFakeMarketo(t, fakemarketo.Succeed, nil)
+
+	confirmation, err := client.Unsubscribe(context.Background(), "jordan.abbott@my.utsa.edu")
+	if err != nil {
+		t.Fatalf("Unsubscribe: %v", err)
+	}
+	if confirmation == nil || confirmation.FormId != client.Form.FormId || confirmation.AliId == "" {
+		t.Errorf("confirmation = %+v, want the form's ID and an aliId", confirmation)
+	}
+
+	submissions := fake.Submissions()
+	if len(submissions) != 1 {
+		t.Fatalf("fake received %d submissions, want 1", len(submissions))
+	}
+	if submissions[0].Email != "jordan.abbott@my.utsa.edu" || submissions[0].Result != fakemarketo.ResultSucceeded {
+		t.Errorf("submission = %s %s, want the email to have succeeded", submissions[0].Email, submissions[0].Result)
+	}
+	if submissions[0].Values.Get("munchkinId") != client.Form.MunchkinId {
+		t.Errorf("submitted munchkinId = %q, want %q", submissions[0].Values.Get("munchkinId"), client.Form.MunchkinId)
+	}
+}
+
+func TestUnsubscribeErrors(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		behavior fakemarketo.Behavior
+		tamper   func(values url.Values)
+		check    func(err error) bool
+		errType  string
+	}{
+		{
+			name:     "checksum missing",
+			behavior: fakemarketo.Succeed,
+			tamper:   func(values url.Values) { values.Del("checksum") },
+			check:    func(err error) bool { return errors.As(err, new(scla.ChecksumMissingError)) },
+			errType:  "checksum_missing",
+		},
+		{
+			name:     "checksum invalid",
+			behavior: fakemarketo.Succeed,
+			tamper:   func(values url.Values) { values.Set("Email", "someone.else@my.utsa.edu") },
+			check:    func(err error) bool { return errors.As(err, new(scla.ChecksumInvalidError)) },
+			errType:  "checksum_invalid",
+		},
+		{
+			name:     "rejected",
+			behavior: fakemarketo.Reject,
+			check:    func(err error) bool { return errors.As(err, new(scla.UnsubscribeRejectedError)) },
+			errType:  "rejected",
+		},
+		{
+			name:     "html error",
+			behavior: fakemarketo.HTMLError,
+			check:    unexpectedWithCode(http.StatusInternalServerError),
+			errType:  "unexpected",
+		},
+		{
+			name:     "unknown json error",
+			behavior: fakemarketo.UnknownError,
+			check:    unexpectedWithCode(http.StatusBadRequest),
+			errType:  "unexpected",
+		},
+		{
+			name:     "malformed json",
+			behavior: fakemarketo.MalformedJSON,
+			check:    unexpectedWithCode(http.StatusBadRequest),
+			errType:  "unexpected",
+		},
+		{
+			name:     "rate limited",
+			behavior: fakemarketo.RateLimit,
+			check:    unexpectedWithCode(http.StatusTooManyRequests),
+			errType:  "unexpected",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, client := newFakeMarketo(t, test.behavior, test.tamper)
+
+			confirmation, err := client.Unsubscribe(context.Background(), "jordan.abbott@my.utsa.edu")
+			if err == nil {
+				t.Fatalf("Unsubscribe succeeded with %+v, want an error", confirmation)
+			}
+			if !test.check(err) {
+				t.Errorf("Unsubscribe returned %T: %v", err, err)
+			}
+			if errType := scla.ErrorType(err); errType != test.errType {
+				t.Errorf("ErrorType = %q, want %q", errType, test.errType)
+			}
+		})
+	}
+}
+
+func unexpectedWithCode(code int) func(err error) bool {
+	return func(err error) bool {
+		var unexpected scla.UnsubscribeUnexpectedError
+		return errors.As(err, &unexpected) && unexpected.Code == code
+	}
+}
+
+func TestUnsubscribeRetriesRateLimit(t *testing.T) {
+	fake, client := newFakeMarketo(t, fakemarketo.Succeed, nil)
+	fake.Queue(fakemarketo.RateLimit, fakemarketo.RateLimit)
+
+	if _, err := client.Unsubscribe(context.Background(), "jordan.abbott@my.utsa.edu"); err != nil {
+		t.Fatalf("Unsubscribe after two rate limits: %v", err)
+	}
+
+	results := make([]fakemarketo.Result, 0, 3)
+	for _, submission := range fake.Submissions() {
+		results = append(results, submission.Result)
+	}
+	want := []fakemarketo.Result{fakemarketo.ResultRateLimited, fakemarketo.ResultRateLimited, fakemarketo.ResultSucceeded}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("submission results = %v, want %v", results, want)
+	}
+}
+
+func TestUnsubscribeGivesUpAfterMaxAttempts(t *testing.T) {
+	fake, client := newFakeMarketo(t, fakemarketo.RateLimit, nil)
+
+	_, err := client.Unsubscribe(context.Background(), "jordan.abbott@my.utsa.edu")
+	if !unexpectedWithCode(http.StatusTooManyRequests)(err) {
+		t.Fatalf("Unsubscribe while rate limited = %v, want an UnsubscribeUnexpectedError with code 429", err)
+	}
+	if errType := scla.ErrorType(err); errType != "unexpected" {
+		t.Errorf("ErrorType = %q, want %q", errType, "unexpected")
+	}
+	if submissions := fake.Submissions(); len(submissions) != testRetryPolicy.MaxAttempts {
+		t.Errorf("form was submitted %d times, want %d", len(submissions), testRetryPolicy.MaxAttempts)
+	}
+}
+
+func TestUnsubscribeServerErrorIsNotRetried(t *testing.T) {
+	fake, client := newFakeMarketo(t, fakemarketo.Succeed, nil)
+	fake.Queue(fakemarketo.HTMLError)
+
+	// The server may have acted on a form it answered with a 500, so it is not sent again even though the next would succeed
+	_, err := client.Unsubscribe(context.Background(), "jordan.abbott@my.utsa.edu")
+	if !unexpectedWithCode(http.StatusInternalServerError)(err) {
+		t.Fatalf("Unsubscribe after a server error = %v, want an UnsubscribeUnexpectedError with code 500", err)
+	}
+	if errType := scla.ErrorType(err); errType != "unexpected" {
+		t.Errorf("ErrorType = %q, want %q", errType, "unexpected")
+	}
+	if submissions := fake.Submissions(); len(submissions) != 1 || submissions[0].Code != http.StatusInternalServerError {
+		t.Errorf("submissions = %+v, want a single one answered with a 500", submissions)
+	}
+}
+
+func TestUnsubscribeWrongMunchkinIdIsRejected(t *testing.T) {
+	fake, client := newFakeMarketo(t, fakemarketo.Succeed, nil)
+	fake.MunchkinId = "000-AAA-000"
+
+	_, err := client.Unsubscribe(context.Background(), "jordan.abbott@my.utsa.edu")
+	if !errors.As(err, new(scla.UnsubscribeRejectedError)) {
+		t.Fatalf("Unsubscribe with the wrong munchkin ID = %v, want an UnsubscribeRejectedError", err)
+	}
+}
+
+func TestTryUnsubscribeRecordsOutcome(t *testing.T) {
+	ctx := context.Background()
+	fake, client := newFakeMarketo(t, fakemarketo.Reject, nil)
+	rejected := metrics.Unsubscribes.WithLabelValues(string(scla.OutcomeFailed), "rejected", "false")
+	before := testutil.ToFloat64(rejected)
+
+	// A rejection is recorded and counted under its reason, and does not stop a later attempt
+	if outcome, err := client.TryUnsubscribe(ctx, "Jordan.Abbott@my.utsa.edu"); err == nil || outcome != scla.OutcomeFailed {
+		t.Fatalf("TryUnsubscribe = %s, %v; want a failed rejection", outcome, err)
+	}
+	if counted := testutil.ToFloat64(rejected) - before; counted != 1 {
+		t.Errorf("rejection was counted %v times, want once", counted)
+	}
+	record, err := client.GetRecord("jordan.abbott@my.utsa.edu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Status != scla.StatusRejected || record.Attempts != 1 || record.LastErrorType != "rejected" {
+		t.Errorf("record after rejection = %+v", record)
+	}
+
+	fake.SetBehavior(fakemarketo.Succeed)
+	outcome, err := client.TryUnsubscribe(ctx, "jordan.abbott@my.utsa.edu")
+	if err != nil || outcome != scla.OutcomeUnsubscribed {
+		t.Fatalf("TryUnsubscribe = %s, %v; want unsubscribed, nil", outcome, err)
+	}
+	record, err = client.GetRecord("jordan.abbott@my.utsa.edu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Status != scla.StatusUnsubscribed || record.Attempts != 2 || record.LastError != "" || record.AliId == "" {
+		t.Errorf("record after success = %+v", record)
+	}
+
+	// Once unsubscribed, nothing more is sent
+	outcome, err = client.TryUnsubscribe(ctx, "jordan.abbott@my.utsa.edu")
+	if err != nil || outcome != scla.OutcomeSkipped {
+		t.Fatalf("TryUnsubscribe of an unsubscribed email = %s, %v; want skipped, nil", outcome, err)
+	}
+	if len(fake.Submissions()) != 2 {
+		t.Errorf("fake received %d submissions, want 2", len(fake.Submissions()))
+	}
+}
+
+func TestUnsubscribeDecoyCountsOutcome(t *testing.T) {
+	fake, client := newFakeMarketo(t, fakemarketo.Succeed, nil)
+	decoys := metrics.Unsubscribes.WithLabelValues(string(scla.OutcomeUnsubscribed), "", "true")
+	unsubscribed := metrics.Unsubscribes.WithLabelValues(string(scla.OutcomeUnsubscribed), "", "false")
+	before, beforeReal := testutil.ToFloat64(decoys), testutil.ToFloat64(unsubscribed)
+
+	email := scla.FakeEmail()
+	if outcome, err := client.UnsubscribeDecoy(context.Background(), email); err != nil || outcome != scla.OutcomeUnsubscribed {
+		t.Fatalf("UnsubscribeDecoy = %s, %v; want unsubscribed, nil", outcome, err)
+	}
+	if counted := testutil.ToFloat64(decoys) - before; counted != 1 {
+		t.Errorf("decoy was counted %v times, want once", counted)
+	}
+	if counted := testutil.ToFloat64(unsubscribed) - beforeReal; counted != 0 {
+		t.Errorf("decoy was counted %v times as a real unsubscribe, want none", counted)
+	}
+	if len(fake.Submissions()) != 1 {
+		t.Errorf("fake received %d submissions, want 1", len(fake.Submissions()))
+	}
+
+	// Nothing is recorded for it
+	if record, err := client.GetRecord(email); err != nil || record.Attempts != 0 {
+		t.Errorf("record of a decoy = %+v, %v; want none", record, err)
+	}
+}
+
+func TestDryRunSubmitsNothing(t *testing.T) {
+	fake, client := newFakeMarketo(t, fakemarketo.Succeed, nil)
+	var output bytes.Buffer
+	client.DryRun = true
+	client.DryRunOutput = &output
+
+	outcome, err := client.TryUnsubscribe(context.Background(), "jordan.abbott@my.utsa.edu")
+	if err != nil || outcome != scla.OutcomeDryRun {
+		t.Fatalf("dry run TryUnsubscribe = %s, %v; want dry_run, nil", outcome, err)
+	}
+	if len(fake.Submissions()) != 0 {
+		t.Errorf("fake received %d submissions in dry-run mode, want 0", len(fake.Submissions()))
+	}
+
+	var form struct {
+		Email  string     `json:"email"`
+		Decoy  bool       `json:"decoy"`
+		Values url.Values `json:"values"`
+	}
+	if err := json.Unmarshal(output.Bytes(), &form); err != nil {
+		t.Fatalf("dry run output %q: %v", output.String(), err)
+	}
+	if form.Email != "jordan.abbott@my.utsa.edu" || form.Decoy || form.Values.Get("checksum") == "" {
+		t.Errorf("dry run form = %+v, want the email and its checksum", form)
+	}
+
+	// A decoy's form is written out too, but marked as one so it is not mistaken for a real email
+	output.Reset()
+	decoy := scla.FakeEmail()
+	if outcome, err := client.UnsubscribeDecoy(context.Background(), decoy); err != nil || outcome != scla.OutcomeDryRun {
+		t.Fatalf("dry run UnsubscribeDecoy = %s, %v; want dry_run, nil", outcome, err)
+	}
+	if err := json.Unmarshal(output.Bytes(), &form); err != nil {
+		t.Fatalf("dry run decoy output %q: %v", output.String(), err)
+	}
+	if form.Email != decoy || !form.Decoy {
+		t.Errorf("dry run decoy form = %+v, want %s marked as a decoy", form, decoy)
+	}
+	if len(fake.Submissions()) != 0 {
+		t.Errorf("fake received %d submissions in dry-run mode, want 0", len(fake.Submissions()))
+	}
+}
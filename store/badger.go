@@ -0,0 +1,176 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+	"github.com/pkg/errors"
+
+	"unsubscribe/directory"
+	"unsubscribe/scla"
+)
+
+// BadgerStore is a Store backed by a badger database on disk
+type BadgerStore struct {
+	db *badger.DB
+}
+
+// OpenBadgerStore opens (or creates) a badger database at the given path
+func OpenBadgerStore(path string) (*BadgerStore, error) {
+	options := badger.DefaultOptions(path).WithLogger(badgerZerologLogger{level: WARNING})
+	db, err := badger.Open(options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BadgerStore{db: db}, nil
+}
+
+// getJSON reads the value at key into target, returning false if the key does not exist
+func (s *BadgerStore) getJSON(key string, target interface{}) (bool, error) {
+	found := false
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(key))
+		if err == badger.ErrKeyNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		found = true
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, target)
+		})
+	})
+
+	return found, err
+}
+
+// cachedValue wraps a cached value with the time it was cached, so that expiry can be decided by the reader
+type cachedValue struct {
+	CachedAt time.Time       `json:"cachedAt"`
+	Value    json.RawMessage `json:"value"`
+}
+
+// getCached reads a value written by setCached into target, returning the time it was cached.
+// Values written before timestamps were stored are returned with a zero time, so they are always considered expired.
+func (s *BadgerStore) getCached(key string, target interface{}) (time.Time, bool, error) {
+	var raw json.RawMessage
+	found, err := s.getJSON(key, &raw)
+	if err != nil || !found {
+		return time.Time{}, false, err
+	}
+
+	var cached cachedValue
+	if err := json.Unmarshal(raw, &cached); err != nil || len(cached.Value) == 0 {
+		return time.Time{}, true, json.Unmarshal(raw, target)
+	}
+
+	return cached.CachedAt, true, json.Unmarshal(cached.Value, target)
+}
+
+// setCached marshals value and stores it at key along with the current time
+func (s *BadgerStore) setCached(key string, value interface{}) error {
+	marshalled, err := json.Marshal(value)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal value")
+	}
+
+	return s.setJSON(key, cachedValue{CachedAt: time.Now(), Value: marshalled})
+}
+
+// setJSON marshals value and stores it at key
+func (s *BadgerStore) setJSON(key string, value interface{}) error {
+	marshalled, err := json.Marshal(value)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal value")
+	}
+
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(key), marshalled)
+	})
+}
+
+func (s *BadgerStore) LoadCookies() ([]http.Cookie, error) {
+	var cookies []http.Cookie
+	_, err := s.getJSON(cookiesKey, &cookies)
+	return cookies, err
+}
+
+func (s *BadgerStore) SaveCookies(cookies []http.Cookie) error {
+	return s.setJSON(cookiesKey, cookies)
+}
+
+func (s *BadgerStore) GetDirectory(letter rune) ([]directory.Entry, time.Time, bool, error) {
+	entries := make([]directory.Entry, 0, 500)
+	cachedAt, found, err := s.getCached(directoryKey(letter), &entries)
+	if err != nil || !found {
+		return nil, time.Time{}, false, err
+	}
+	return entries, cachedAt, true, nil
+}
+
+func (s *BadgerStore) SetDirectory(letter rune, entries []directory.Entry) error {
+	return s.setCached(directoryKey(letter), entries)
+}
+
+func (s *BadgerStore) GetEntry(id string) (*directory.FullEntry, time.Time, bool, error) {
+	var entry directory.FullEntry
+	cachedAt, found, err := s.getCached(entryKey(id), &entry)
+	if err != nil || !found {
+		return nil, time.Time{}, false, err
+	}
+	return &entry, cachedAt, true, nil
+}
+
+func (s *BadgerStore) SetEntry(id string, entry *directory.FullEntry) error {
+	return s.setCached(entryKey(id), entry)
+}
+
+// GetRecord reads the unsubscribe record stored under the bare email key.
+// Before records were stored, the value was "1" once unsubscribed or "0" after a failure, which are converted.
+func (s *BadgerStore) GetRecord(email string) (*scla.Record, bool, error) {
+	raw, found, err := s.Get(email)
+	if err != nil || !found {
+		return nil, false, err
+	}
+
+	switch string(raw) {
+	case "1":
+		return &scla.Record{Email: email, Status: scla.StatusUnsubscribed}, true, nil
+	case "0":
+		return &scla.Record{Email: email, Status: scla.StatusFailed}, true, nil
+	}
+
+	var record scla.Record
+	if err := json.Unmarshal(raw, &record); err != nil {
+		return nil, false, fmt.Errorf("invalid value for email %s: %w", email, err)
+	}
+	return &record, true, nil
+}
+
+func (s *BadgerStore) PutRecord(record *scla.Record) error {
+	return s.setJSON(record.Email, record)
+}
+
+func (s *BadgerStore) Clear(family string) (int, error) {
+	var keys [][]byte
+	err := s.iterate("", func(item *badger.Item) error {
+		if KeyFamily(string(item.Key())) == family {
+			keys = append(keys, item.KeyCopy(nil))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(keys), s.deleteKeys(keys)
+}
+
+func (s *BadgerStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,143 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+
+	"unsubscribe/directory"
+	"unsubscribe/scla"
+)
+
+// openTestBadgerStore opens a badger store in a temporary directory, closing it once the test is over
+func openTestBadgerStore(t *testing.T) *BadgerStore {
+	t.Helper()
+	s, err := OpenBadgerStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestCachedValuesKeepTheirTime(t *testing.T) {
+	s := openTestBadgerStore(t)
+	entry := &directory.FullEntry{Name: "Jordan Abbott", Email: "jordan.abbott@my.utsa.edu"}
+
+	before := time.Now()
+	if err := s.SetEntry("abc", entry); err != nil {
+		t.Fatal(err)
+	}
+	loaded, cachedAt, found, err := s.GetEntry("abc")
+	if err != nil || !found || !reflect.DeepEqual(loaded, entry) {
+		t.Fatalf("GetEntry = %+v, %t, %v; want %+v", loaded, found, err, entry)
+	}
+	if cachedAt.Before(before) || cachedAt.After(time.Now()) {
+		t.Errorf("cached at %s, want the time it was set", cachedAt)
+	}
+
+	if _, _, found, err := s.GetEntry("missing"); err != nil || found {
+		t.Errorf("GetEntry of a missing ID = %t, %v; want false, nil", found, err)
+	}
+}
+
+func TestLegacyCachedValues(t *testing.T) {
+	s := openTestBadgerStore(t)
+
+	// Values cached before timestamps were stored are the bare JSON value
+	entries := []directory.Entry{{Id: "abc", Name: "Jordan Abbott"}, {Id: "def", Name: "Riley Adams"}}
+	if err := s.setJSON(directoryKey('A'), entries); err != nil {
+		t.Fatal(err)
+	}
+	entry := &directory.FullEntry{Name: "Jordan Abbott", Email: "jordan.abbott@my.utsa.edu"}
+	if err := s.setJSON(entryKey("abc"), entry); err != nil {
+		t.Fatal(err)
+	}
+
+	// They are still read, but with a zero time so that they count as expired
+	loadedEntries, cachedAt, found, err := s.GetDirectory('A')
+	if err != nil || !found || !reflect.DeepEqual(loadedEntries, entries) || !cachedAt.IsZero() {
+		t.Errorf("GetDirectory of a legacy value = %+v, %s, %t, %v; want %+v with a zero time", loadedEntries, cachedAt, found, err, entries)
+	}
+	loadedEntry, cachedAt, found, err := s.GetEntry("abc")
+	if err != nil || !found || !reflect.DeepEqual(loadedEntry, entry) || !cachedAt.IsZero() {
+		t.Errorf("GetEntry of a legacy value = %+v, %s, %t, %v; want %+v with a zero time", loadedEntry, cachedAt, found, err, entry)
+	}
+}
+
+func TestKeysReportWhenValuesWereCached(t *testing.T) {
+	s := openTestBadgerStore(t)
+
+	before := time.Now()
+	if err := s.SetEntry("abc", &directory.FullEntry{Name: "Jordan Abbott"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.setJSON(entryKey("def"), &directory.FullEntry{Name: "Riley Adams"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutRecord(&scla.Record{Email: "jordan.abbott@my.utsa.edu", Status: scla.StatusUnsubscribed}); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := s.Keys("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cachedAt := make(map[string]time.Time)
+	for _, key := range keys {
+		cachedAt[key.Key] = key.CachedAt
+	}
+
+	if actual := cachedAt[entryKey("abc")]; actual.Before(before) || actual.After(time.Now()) {
+		t.Errorf("entry cached at %s, want the time it was set", actual)
+	}
+	// Legacy values and records have no cache time
+	if actual := cachedAt[entryKey("def")]; !actual.IsZero() {
+		t.Errorf("legacy entry cached at %s, want a zero time", actual)
+	}
+	if actual, found := cachedAt["jordan.abbott@my.utsa.edu"]; !found || !actual.IsZero() {
+		t.Errorf("record cached at %s (listed %t), want a zero time", actual, found)
+	}
+}
+
+func TestLegacyRecords(t *testing.T) {
+	s := openTestBadgerStore(t)
+
+	// Before records were stored, an email's value was "1" once unsubscribed or "0" after a failure
+	for email, value := range map[string]string{"jordan.abbott@my.utsa.edu": "1", "riley.adams@my.utsa.edu": "0", "casey.allen@my.utsa.edu": "yes"} {
+		err := s.db.Update(func(txn *badger.Txn) error {
+			return txn.Set([]byte(email), []byte(value))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := map[string]scla.Status{"jordan.abbott@my.utsa.edu": scla.StatusUnsubscribed, "riley.adams@my.utsa.edu": scla.StatusFailed}
+	for email, status := range cases {
+		want := &scla.Record{Email: email, Status: status}
+		if record, found, err := s.GetRecord(email); err != nil || !found || !reflect.DeepEqual(record, want) {
+			t.Errorf("GetRecord(%s) = %+v, %t, %v; want %+v", email, record, found, err, want)
+		}
+	}
+
+	// Anything else is neither a legacy value nor a record
+	if record, found, err := s.GetRecord("casey.allen@my.utsa.edu"); err == nil {
+		t.Errorf("GetRecord of an invalid value = %+v, %t, nil; want an error", record, found)
+	}
+
+	// Once written again, a migrated record is stored as JSON
+	record, _, _ := s.GetRecord("riley.adams@my.utsa.edu")
+	record.Attempts = 1
+	if err := s.PutRecord(record); err != nil {
+		t.Fatal(err)
+	}
+	if raw, _, err := s.Get("riley.adams@my.utsa.edu"); err != nil || string(raw) == "0" {
+		t.Errorf("stored value = %q, %v; want a JSON record", raw, err)
+	}
+	if migrated, _, err := s.GetRecord("riley.adams@my.utsa.edu"); err != nil || !reflect.DeepEqual(migrated, record) {
+		t.Errorf("GetRecord after migrating = %+v, %v; want %+v", migrated, err, record)
+	}
+}
@@ -0,0 +1,172 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// KeyInfo describes a single key in the database, without its value
+type KeyInfo struct {
+	Key      string
+	Family   string
+	Size     int64     // Size of the value, in bytes
+	CachedAt time.Time // When a directory page or entry was cached, zero for other families and legacy values
+}
+
+// FamilyStats summarizes every key belonging to a key family
+type FamilyStats struct {
+	Count int
+	Size  int64 // Combined size of the keys and values, in bytes
+}
+
+// Keys lists every key starting with prefix, in key order.
+// Only the values of cached families are read, for the time they were cached; expiry is left to the cache policy.
+func (s *BadgerStore) Keys(prefix string) ([]KeyInfo, error) {
+	var keys []KeyInfo
+	err := s.iterate(prefix, func(item *badger.Item) error {
+		info := KeyInfo{
+			Key:    string(item.Key()),
+			Family: KeyFamily(string(item.Key())),
+			Size:   item.ValueSize(),
+		}
+
+		if info.Family == FamilyDirectory || info.Family == FamilyEntry {
+			err := item.Value(func(val []byte) error {
+				// Legacy values are not wrapped, so they fail to decode or have no time, and are left at zero
+				var cached cachedValue
+				if json.Unmarshal(val, &cached) == nil {
+					info.CachedAt = cached.CachedAt
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		keys = append(keys, info)
+		return nil
+	})
+
+	return keys, err
+}
+
+// Get returns the raw value stored at key, returning false if it does not exist
+func (s *BadgerStore) Get(key string) ([]byte, bool, error) {
+	var value []byte
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(key))
+		if err != nil {
+			return err
+		}
+
+		value, err = item.ValueCopy(nil)
+		return err
+	})
+
+	if err == badger.ErrKeyNotFound {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Delete removes a single key, returning false if it did not exist
+func (s *BadgerStore) Delete(key string) (bool, error) {
+	found := true
+	err := s.db.Update(func(txn *badger.Txn) error {
+		_, err := txn.Get([]byte(key))
+		if err == badger.ErrKeyNotFound {
+			found = false
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		return txn.Delete([]byte(key))
+	})
+
+	return found, err
+}
+
+// DeletePrefix removes every key starting with prefix, returning the number of keys deleted
+func (s *BadgerStore) DeletePrefix(prefix string) (int, error) {
+	var keys [][]byte
+	err := s.iterate(prefix, func(item *badger.Item) error {
+		keys = append(keys, item.KeyCopy(nil))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(keys), s.deleteKeys(keys)
+}
+
+// Stats counts the keys and their sizes for each key family
+func (s *BadgerStore) Stats() (map[string]FamilyStats, error) {
+	stats := make(map[string]FamilyStats, len(Families))
+	for _, family := range Families {
+		stats[family] = FamilyStats{}
+	}
+
+	err := s.iterate("", func(item *badger.Item) error {
+		family := KeyFamily(string(item.Key()))
+		familyStats := stats[family]
+		familyStats.Count++
+		familyStats.Size += int64(len(item.Key())) + item.ValueSize()
+		stats[family] = familyStats
+		return nil
+	})
+
+	return stats, err
+}
+
+// RunGC rewrites value log files until badger finds nothing left worth rewriting, returning the number of rewrites.
+// A file is rewritten if at least discardRatio of it can be discarded.
+func (s *BadgerStore) RunGC(discardRatio float64) (int, error) {
+	rewrites := 0
+	for {
+		err := s.db.RunValueLogGC(discardRatio)
+		if err == badger.ErrNoRewrite {
+			return rewrites, nil
+		} else if err != nil {
+			return rewrites, err
+		}
+		rewrites++
+	}
+}
+
+// iterate calls fn with every item whose key starts with prefix, without fetching values
+func (s *BadgerStore) iterate(prefix string, fn func(item *badger.Item) error) error {
+	return s.db.View(func(txn *badger.Txn) error {
+		options := badger.DefaultIteratorOptions
+		options.PrefetchValues = false
+		options.Prefix = []byte(prefix)
+		it := txn.NewIterator(options)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			if err := fn(it.Item()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// deleteKeys removes keys using a write batch, which splits itself up as needed unlike a single transaction
+func (s *BadgerStore) deleteKeys(keys [][]byte) error {
+	batch := s.db.NewWriteBatch()
+	defer batch.Cancel()
+	for _, key := range keys {
+		if err := batch.Delete(key); err != nil {
+			return err
+		}
+	}
+
+	return batch.Flush()
+}
@@ -0,0 +1,132 @@
+package store
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"unsubscribe/directory"
+	"unsubscribe/scla"
+)
+
+// MemoryStore is a Store that only lives in memory, intended for tests and throwaway runs
+type MemoryStore struct {
+	mu          sync.RWMutex
+	cookies     []http.Cookie
+	directories map[rune][]directory.Entry
+	entries     map[string]directory.FullEntry
+	records     map[string]scla.Record
+	cachedAt    map[string]time.Time // Keyed by the same keys the badger store uses
+}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		directories: make(map[rune][]directory.Entry),
+		entries:     make(map[string]directory.FullEntry),
+		records:     make(map[string]scla.Record),
+		cachedAt:    make(map[string]time.Time),
+	}
+}
+
+func (s *MemoryStore) LoadCookies() ([]http.Cookie, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]http.Cookie(nil), s.cookies...), nil
+}
+
+func (s *MemoryStore) SaveCookies(cookies []http.Cookie) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cookies = append([]http.Cookie(nil), cookies...)
+	return nil
+}
+
+func (s *MemoryStore) GetDirectory(letter rune) ([]directory.Entry, time.Time, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entries, found := s.directories[letter]
+	if !found {
+		return nil, time.Time{}, false, nil
+	}
+	return append([]directory.Entry(nil), entries...), s.cachedAt[directoryKey(letter)], true, nil
+}
+
+func (s *MemoryStore) SetDirectory(letter rune, entries []directory.Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.directories[letter] = append([]directory.Entry(nil), entries...)
+	s.cachedAt[directoryKey(letter)] = time.Now()
+	return nil
+}
+
+func (s *MemoryStore) GetEntry(id string) (*directory.FullEntry, time.Time, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entry, found := s.entries[id]
+	if !found {
+		return nil, time.Time{}, false, nil
+	}
+	return &entry, s.cachedAt[entryKey(id)], true, nil
+}
+
+func (s *MemoryStore) SetEntry(id string, entry *directory.FullEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[id] = *entry
+	s.cachedAt[entryKey(id)] = time.Now()
+	return nil
+}
+
+func (s *MemoryStore) GetRecord(email string) (*scla.Record, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	record, found := s.records[email]
+	if !found {
+		return nil, false, nil
+	}
+	return &record, true, nil
+}
+
+func (s *MemoryStore) PutRecord(record *scla.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[record.Email] = *record
+	return nil
+}
+
+func (s *MemoryStore) Clear(family string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var count int
+	switch family {
+	case FamilyCookies:
+		if s.cookies != nil {
+			count = 1
+		}
+		s.cookies = nil
+	case FamilyDirectory:
+		count = len(s.directories)
+		s.directories = make(map[rune][]directory.Entry)
+	case FamilyEntry:
+		count = len(s.entries)
+		s.entries = make(map[string]directory.FullEntry)
+	case FamilyEmail:
+		count = len(s.records)
+		s.records = make(map[string]scla.Record)
+	}
+
+	// Forget the cache times of whatever was just cleared
+	for key := range s.cachedAt {
+		if KeyFamily(key) == family {
+			delete(s.cachedAt, key)
+		}
+	}
+
+	return count, nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,58 @@
+// Package store persists cookies, cached directory data and unsubscribe state between runs.
+package store
+
+import (
+	"fmt"
+	"strings"
+
+	"unsubscribe/directory"
+	"unsubscribe/scla"
+)
+
+// Store persists everything that needs to survive between runs: login cookies, cached directory pages,
+// cached full entries and the unsubscribe state of each email.
+//
+// Getters return a boolean indicating whether the value was found, so that a missing key is not treated as an error.
+type Store interface {
+	directory.Cache
+	scla.State
+
+	// Clear deletes every key in a family, returning the number of keys deleted
+	Clear(family string) (int, error)
+
+	Close() error
+}
+
+// Key families, each of which is stored under its own key scheme
+const (
+	FamilyCookies   = "cookies"   // utsa_cookies
+	FamilyDirectory = "directory" // directory:<letter>
+	FamilyEntry     = "entry"     // entry:<id>
+	FamilyEmail     = "email"     // <email>
+)
+
+var Families = []string{FamilyCookies, FamilyDirectory, FamilyEntry, FamilyEmail}
+
+// KeyFamily returns the family a raw key belongs to
+func KeyFamily(key string) string {
+	switch {
+	case key == cookiesKey:
+		return FamilyCookies
+	case strings.HasPrefix(key, "directory:"):
+		return FamilyDirectory
+	case strings.HasPrefix(key, "entry:"):
+		return FamilyEntry
+	default:
+		return FamilyEmail
+	}
+}
+
+const cookiesKey = "utsa_cookies"
+
+func directoryKey(letter rune) string {
+	return fmt.Sprintf("directory:%s", string(letter))
+}
+
+func entryKey(id string) string {
+	return fmt.Sprintf("entry:%s", id)
+}
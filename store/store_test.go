@@ -0,0 +1,65 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"unsubscribe/scla"
+)
+
+// testStores returns an empty instance of every Store, keyed by name
+func testStores(t *testing.T) map[string]Store {
+	return map[string]Store{
+		"badger": openTestBadgerStore(t),
+		"memory": NewMemoryStore(),
+	}
+}
+
+func TestRecordRoundTrip(t *testing.T) {
+	first := time.Date(2024, 1, 15, 9, 30, 0, 0, time.UTC)
+	pending := &scla.Record{Email: "jordan.abbott@my.utsa.edu", Status: scla.StatusPending, Attempts: 1, FirstAttempt: first, LastAttempt: first}
+	failed := &scla.Record{
+		Email: "riley.adams@my.utsa.edu", Status: scla.StatusFailed, Attempts: 2, FirstAttempt: first, LastAttempt: first.Add(time.Hour),
+		LastError: "unexpected status code 500", LastErrorType: "unexpected",
+	}
+	unsubscribed := &scla.Record{
+		Email: "jordan.abbott@my.utsa.edu", Status: scla.StatusUnsubscribed, Attempts: 2, FirstAttempt: first, LastAttempt: first.Add(time.Minute),
+		FormId: "1", FollowUpUrl: "https://www2.thescla.org/UnsubscribeConfirmation.html", AliId: "42",
+	}
+
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if record, found, err := s.GetRecord(pending.Email); err != nil || found {
+				t.Fatalf("GetRecord before any attempt = %+v, %t, %v; want nothing", record, found, err)
+			}
+
+			for _, record := range []*scla.Record{pending, failed} {
+				if err := s.PutRecord(record); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, want := range []*scla.Record{pending, failed} {
+				if record, found, err := s.GetRecord(want.Email); err != nil || !found || !reflect.DeepEqual(record, want) {
+					t.Errorf("GetRecord(%s) = %+v, %t, %v; want %+v", want.Email, record, found, err, want)
+				}
+			}
+
+			// A later attempt replaces the record
+			if err := s.PutRecord(unsubscribed); err != nil {
+				t.Fatal(err)
+			}
+			if record, _, err := s.GetRecord(unsubscribed.Email); err != nil || !reflect.DeepEqual(record, unsubscribed) {
+				t.Errorf("GetRecord after unsubscribing = %+v, %v; want %+v", record, err, unsubscribed)
+			}
+
+			// Clearing the family forgets every record
+			if count, err := s.Clear(FamilyEmail); err != nil || count != 2 {
+				t.Errorf("Clear = %d, %v; want 2, nil", count, err)
+			}
+			if _, found, err := s.GetRecord(failed.Email); err != nil || found {
+				t.Errorf("GetRecord after clearing = %t, %v; want false, nil", found, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package web
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/samber/lo"
+
+	"unsubscribe/ratelimit"
+)
+
+// RetryPolicy decides whether a failed request is retried, and how long to wait before doing so
+type RetryPolicy struct {
+	MaxAttempts     int           // Attempts in total, including the first; 1 disables retrying
+	BaseDelay       time.Duration // Delay before the first retry, doubled for each retry after
+	MaxDelay        time.Duration // Upper bound for the doubled delay and any Retry-After, 0 for none
+	Jitter          float64       // Fraction of each delay that is randomized, from 0 to 1
+	RetryableStatus []int         // Status codes worth retrying; network errors are always retried
+}
+
+// DefaultRetryPolicy is used for any domain without a policy of its own
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:     3,
+	BaseDelay:       time.Second,
+	MaxDelay:        30 * time.Second,
+	Jitter:          0.5,
+	RetryableStatus: []int{http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+}
+
+// DefaultRetryPolicies are the retry policies every Client starts with, keyed by simplified domain like ratelimit.DefaultLimits
+var DefaultRetryPolicies = map[string]RetryPolicy{
+	// Directory pages are slow to generate, so give the server longer to recover
+	"utsa.edu": {
+		MaxAttempts:     4,
+		BaseDelay:       2 * time.Second,
+		MaxDelay:        time.Minute,
+		Jitter:          0.5,
+		RetryableStatus: DefaultRetryPolicy.RetryableStatus,
+	},
+	"thescla.org": DefaultRetryPolicy,
+}
+
+// RetryPolicy returns the client's retry policy for the domain of the given host
+func (c *Client) RetryPolicy(host string) RetryPolicy {
+	policy, ok := c.RetryPolicies[ratelimit.SimplifyUrlToDomain(host)]
+	if !ok {
+		return DefaultRetryPolicy
+	}
+	return policy
+}
+
+// retryable returns true if an attempt's response or error is worth retrying.
+// Requests that are not idempotent (such as form submissions) may have been acted on even though they failed,
+// so they are only retried if they were never sent, or were turned away with a 429 before reaching the server.
+func (p RetryPolicy) retryable(req *http.Request, resp *http.Response, err error) bool {
+	if !idempotent(req.Method) {
+		if err != nil {
+			return neverSent(err)
+		}
+		return resp.StatusCode == http.StatusTooManyRequests && lo.Contains(p.RetryableStatus, resp.StatusCode)
+	}
+
+	if err != nil {
+		return true
+	}
+	return lo.Contains(p.RetryableStatus, resp.StatusCode)
+}
+
+// idempotent returns true if sending a request with the given method twice has the same effect as sending it once
+func idempotent(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// neverSent returns true if a request failed before any of it could be sent, as it could not connect
+func neverSent(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// delay returns how long to wait before the given retry (starting at 1), preferring the response's Retry-After header
+func (p RetryPolicy) delay(retry int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			// The server may ask for far longer than is worth waiting
+			if p.MaxDelay > 0 && retryAfter > p.MaxDelay {
+				return p.MaxDelay
+			}
+			return retryAfter
+		}
+	}
+
+	delay := time.Duration(float64(p.BaseDelay) * math.Pow(2, float64(retry-1)))
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	// Randomize part of the delay, so that concurrent requests don't retry in lockstep
+	jitter := float64(delay) * p.Jitter
+	return time.Duration(float64(delay) - jitter + rand.Float64()*jitter)
+}
+
+// parseRetryAfter parses a Retry-After header, given either in seconds or as an HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+
+	return 0, false
+}
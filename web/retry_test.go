@@ -0,0 +1,124 @@
+package web
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRetryable(t *testing.T) {
+	refused := &url.Error{Op: "Post", URL: "https://www2.thescla.org", Err: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}}
+	reset := &url.Error{Op: "Post", URL: "https://www2.thescla.org", Err: &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}}
+
+	cases := []struct {
+		name   string
+		method string
+		code   int
+		err    error
+		want   bool
+	}{
+		{"get ok", http.MethodGet, http.StatusOK, nil, false},
+		{"get not found", http.MethodGet, http.StatusNotFound, nil, false},
+		{"get rate limited", http.MethodGet, http.StatusTooManyRequests, nil, true},
+		{"get server error", http.MethodGet, http.StatusInternalServerError, nil, true},
+		{"get unavailable", http.MethodGet, http.StatusServiceUnavailable, nil, true},
+		{"get connection reset", http.MethodGet, 0, reset, true},
+		{"get other error", http.MethodGet, 0, errors.New("unexpected EOF"), true},
+		{"post ok", http.MethodPost, http.StatusOK, nil, false},
+		{"post rate limited", http.MethodPost, http.StatusTooManyRequests, nil, true},
+		{"post server error", http.MethodPost, http.StatusInternalServerError, nil, false},
+		{"post unavailable", http.MethodPost, http.StatusServiceUnavailable, nil, false},
+		{"post connection refused", http.MethodPost, 0, refused, true},
+		{"post connection reset", http.MethodPost, 0, reset, false},
+		{"post other error", http.MethodPost, 0, errors.New("unexpected EOF"), false},
+	}
+
+	for _, c := range cases {
+		req, _ := http.NewRequest(c.method, "https://www2.thescla.org/index.php/leadCapture/save2", nil)
+		var resp *http.Response
+		if c.err == nil {
+			resp = &http.Response{StatusCode: c.code}
+		}
+		if actual := DefaultRetryPolicy.retryable(req, resp, c.err); actual != c.want {
+			t.Errorf("%s: retryable = %t, expected %t", c.name, actual, c.want)
+		}
+	}
+}
+
+func TestDelay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	cases := []struct {
+		name       string
+		retry      int
+		retryAfter string
+		want       time.Duration
+	}{
+		{"first retry", 1, "", time.Second},
+		{"second retry", 2, "", 2 * time.Second},
+		{"third retry", 3, "", 4 * time.Second},
+		{"capped", 10, "", 10 * time.Second},
+		{"retry after", 1, "5", 5 * time.Second},
+		{"retry after of zero", 3, "0", 0},
+		{"retry after above max", 1, "3600", 10 * time.Second},
+		{"retry after date above max", 1, time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), 10 * time.Second},
+		{"invalid retry after", 2, "soon", 2 * time.Second},
+	}
+
+	for _, c := range cases {
+		resp := &http.Response{Header: http.Header{}}
+		if c.retryAfter != "" {
+			resp.Header.Set("Retry-After", c.retryAfter)
+		}
+		if actual := policy.delay(c.retry, resp); actual != c.want {
+			t.Errorf("%s: delay = %s, expected %s", c.name, actual, c.want)
+		}
+	}
+
+	// Without a response, the delay is only backed off
+	if actual := policy.delay(2, nil); actual != 2*time.Second {
+		t.Errorf("delay without a response = %s, expected 2s", actual)
+	}
+}
+
+func TestDelayJitter(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: time.Second, MaxDelay: 10 * time.Second, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		if actual := policy.delay(2, nil); actual < time.Second || actual > 2*time.Second {
+			t.Fatalf("delay = %s, expected between 1s and 2s", actual)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	cases := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"0", 0, true},
+		{"120", 2 * time.Minute, true},
+		{"-1", 0, false},
+		{"1.5", 0, false},
+		{"soon", 0, false},
+		{"Wed, 21 Oct 2015 07:28:00 GMT", 0, true}, // In the past
+	}
+
+	for _, c := range cases {
+		actual, ok := parseRetryAfter(c.value)
+		if actual != c.want || ok != c.ok {
+			t.Errorf("parseRetryAfter(%q) = %s, %t; expected %s, %t", c.value, actual, ok, c.want, c.ok)
+		}
+	}
+
+	// A date in the future is the time until it, give or take the second it is rounded to
+	actual, ok := parseRetryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	if !ok || actual < 58*time.Second || actual > time.Minute {
+		t.Errorf("parseRetryAfter of a date a minute away = %s, %t; expected about 1m", actual, ok)
+	}
+}
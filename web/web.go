@@ -0,0 +1,200 @@
+// Package web wraps an http.Client with the rate limiting and logging boilerplate used for every request.
+package web
+
+import (
+	"io"
+	"maps"
+	"net/http"
+	"net/http/cookiejar"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/rs/zerolog/log"
+
+	"unsubscribe/metrics"
+	"unsubscribe/ratelimit"
+)
+
+// DefaultUserAgent is sent with every request unless the Client is given another
+const DefaultUserAgent = "Mozilla/5.0 (X11; Linux x86_64; rv:122.0) Gecko/20100101 Firefox/122.0"
+
+// Client sends requests through the per-domain rate limiters
+type Client struct {
+	HTTP      *http.Client
+	Limiters  *ratelimit.LimiterRegistry // Defaults to a registry of its own, starting with ratelimit.DefaultLimits
+	UserAgent string                     // Sent with every request, defaulting to DefaultUserAgent
+
+	// RetryPolicies holds the retry policy of each simplified domain, defaulting to a copy of DefaultRetryPolicies
+	RetryPolicies map[string]RetryPolicy
+}
+
+// NewClient creates a Client with an empty cookie jar that does not follow redirects
+func NewClient() *Client {
+	return NewClientWithTransport(nil)
+}
+
+// NewClientWithTransport creates a Client like NewClient, but sending requests through the given transport.
+// A nil transport uses http.DefaultTransport; tests can pass an httptest.Server's transport instead.
+func NewClientWithTransport(transport http.RoundTripper) *Client {
+	jar, _ := cookiejar.New(nil)
+	return &Client{
+		Limiters:      ratelimit.NewLimiterRegistry(ratelimit.DefaultLimits),
+		UserAgent:     DefaultUserAgent,
+		RetryPolicies: maps.Clone(DefaultRetryPolicies),
+		HTTP: &http.Client{
+			Transport: transport,
+			Jar:       jar,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				// Don't follow redirects
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// send makes a request through the domain's rate limiter, retrying it according to the domain's retry policy.
+// The duration returned is that of the final attempt.
+func (c *Client) send(req *http.Request) (*http.Response, time.Duration, error) {
+	policy := c.RetryPolicy(req.URL.Host)
+	domain := ratelimit.SimplifyUrlToDomain(req.URL.Host)
+	req.Header.Set("User-Agent", c.UserAgent)
+
+	for attempt := 1; ; attempt++ {
+		// The body was consumed by the previous attempt, so a fresh copy is needed
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, 0, err
+			}
+			req.Body = body
+		}
+
+		// Wait for an in-flight slot and then a token from the domain's limiter, giving up if the request is canceled first
+		acquireStart := time.Now()
+		release, err := c.Limiters.Acquire(req.Context(), req.URL.Host)
+		if err != nil {
+			return nil, 0, err
+		}
+		metrics.InFlightWait.WithLabelValues(domain).Observe(time.Since(acquireStart).Seconds())
+		waitStart := time.Now()
+		if err := c.Limiters.Wait(req.Context(), req.URL.Host); err != nil {
+			release()
+			return nil, 0, err
+		}
+		metrics.LimiterWait.WithLabelValues(domain).Observe(time.Since(waitStart).Seconds())
+
+		// Log the request
+		log.Debug().Str("method", req.Method).Str("host", req.Host).Str("url", req.URL.String()).Int("attempt", attempt).Msg("Request")
+
+		// Send the request (while acquiring timings)
+		start := time.Now()
+		resp, err := c.HTTP.Do(req)
+		duration := time.Since(start)
+
+		// The slot is held until the response body is closed
+		code := "error"
+		if err != nil {
+			release()
+		} else {
+			resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+
+			code = strconv.Itoa(resp.StatusCode)
+			metrics.RequestDuration.WithLabelValues(domain).Observe(duration.Seconds())
+
+			// Let adaptive rate limiting back off from slow or rate limited responses
+			limit := c.Limiters.Observe(req.URL.String(), resp.StatusCode, duration)
+			metrics.LimiterRate.WithLabelValues(domain).Set(float64(limit))
+		}
+		metrics.Requests.WithLabelValues(domain, req.Method, code).Inc()
+
+		// Give up if the attempt succeeded, or is not worth (or able to be) retried
+		canRetry := attempt < policy.MaxAttempts && req.Context().Err() == nil && (req.Body == nil || req.GetBody != nil)
+		if !canRetry || !policy.retryable(req, resp, err) {
+			return resp, duration, err
+		}
+
+		delay := policy.delay(attempt, resp)
+		event := log.Warn().Err(err).Str("url", req.URL.String()).Int("attempt", attempt).Str("delay", delay.String())
+		if resp != nil {
+			event.Int("code", resp.StatusCode)
+
+			// Drain the body so the connection can be reused
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		event.Msg("Retrying Request")
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, 0, req.Context().Err()
+		}
+	}
+}
+
+// releasingBody releases a request's in-flight slot once its response body is closed
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
+
+// DoRequestNoRead makes a request and returns the response
+// Compared to DoRequest, this function does not read the response body, and it uses the Content-Length header for the associated log attribute.
+// The caller must close the body, as the domain's in-flight slot is held until then.
+// This function encapsulates the boilerplate for logging.
+func (c *Client) DoRequestNoRead(req *http.Request) (*http.Response, error) {
+	resp, duration, err := c.send(req)
+	if err != nil {
+		log.Error().Err(err).Msg("Request Error")
+		return nil, err
+	}
+
+	contentLength, err := strconv.ParseUint(resp.Header.Get("Content-Length"), 10, 64)
+	if err != nil {
+		contentLength = 0
+	}
+	log.Debug().Int("code", resp.StatusCode).Str("content-type", resp.Header.Get("Content-Type")).Str("content-length", Bytes(contentLength)).
+		Str("duration", duration.String()).Msg("Response")
+
+	return resp, nil
+}
+
+// DoRequest makes a request and returns the response and body
+// This function encapsulates the boilerplate for logging and reading the response body
+func (c *Client) DoRequest(req *http.Request) (*http.Response, []byte, error) {
+	resp, duration, err := c.send(req)
+
+	// Handle errors
+	if err != nil {
+		log.Error().Err(err).Msg("Request Error")
+		return nil, nil, err
+	}
+
+	// Read the body
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+
+	if err != nil {
+		log.Err(err).Int("code", resp.StatusCode).Str("content-type", resp.Header.Get("Content-Type")).Str("content-length", Bytes(uint64(len(body)))).
+			Str("duration", duration.String()).Msg("Response (Unable to Read Body)")
+		return nil, nil, err
+	}
+
+	log.Debug().Int("code", resp.StatusCode).Str("content-type", resp.Header.Get("Content-Type")).Str("content-length", Bytes(uint64(len(body)))).
+		Str("duration", duration.String()).Msg("Response")
+	return resp, body, nil
+}
+
+// Bytes calls humanize.Bytes and removes space characters
+func Bytes(bytes uint64) string {
+	return strings.Replace(humanize.Bytes(bytes), " ", "", -1)
+}